@@ -0,0 +1,43 @@
+package appleapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// AuthStatus describes the cached authentication token of a client, for
+// admin or health endpoints. It never contains the token itself.
+type AuthStatus struct {
+	Cached      bool          `json:"cached"`                // A token is cached
+	ExpiresAt   time.Time     `json:"expiresAt,omitzero"`    // Expiry of the cached token
+	Remaining   time.Duration `json:"remaining"`             // Validity left at the time of the call; 0 once expired
+	Fingerprint string        `json:"fingerprint,omitempty"` // Short hash of the cached token, to tell tokens apart
+}
+
+// cachingProvider is implemented by token providers that can report their
+// cached token, such as *token.TokenProvider.
+type cachingProvider interface {
+	CachedToken() (string, time.Time, bool)
+}
+
+// AuthStatus reports the state of the token cached by the client's token
+// provider at now without generating a token. Providers that do not expose
+// their cache, such as a ProviderFunc, report no cached token.
+func (c *Client) AuthStatus(now time.Time) AuthStatus {
+	p, ok := c.TokenProvider.(cachingProvider)
+	if !ok {
+		return AuthStatus{}
+	}
+	tok, expiresAt, ok := p.CachedToken()
+	if !ok {
+		return AuthStatus{}
+	}
+	sum := sha256.Sum256([]byte(tok))
+	return AuthStatus{
+		Cached:      true,
+		ExpiresAt:   expiresAt,
+		Remaining:   max(expiresAt.Sub(now), 0),
+		Fingerprint: hex.EncodeToString(sum[:6]),
+	}
+}
@@ -0,0 +1,59 @@
+package appleapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+func TestClient_AuthStatus(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	tp := token.NewProvider("KEYID", "TEAMID", priv, token.WithTTL(20*time.Minute))
+	c, err := NewClient(DefaultHTTPClientInitializer(), "", tp)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	if diff := cmp.Diff(AuthStatus{}, c.AuthStatus(now)); diff != "" {
+		t.Errorf("status before GetToken mismatch (-want +got):\n%s", diff)
+	}
+
+	tok, err := tp.GetToken(now)
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+
+	got := c.AuthStatus(now.Add(5 * time.Minute))
+	if len(got.Fingerprint) != 12 || strings.Contains(tok, got.Fingerprint) {
+		t.Errorf("Fingerprint = %q, want a 12-character hash not taken from the token", got.Fingerprint)
+	}
+	got.Fingerprint = ""
+	want := AuthStatus{Cached: true, ExpiresAt: now.Add(20 * time.Minute), Remaining: 15 * time.Minute}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("status after GetToken mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := c.AuthStatus(now.Add(time.Hour)); !got.Cached || got.Remaining != 0 {
+		t.Errorf("status after expiry = %+v, want cached with no remaining validity", got)
+	}
+}
+
+func TestClient_AuthStatus_OpaqueProvider(t *testing.T) {
+	c, err := NewClient(DefaultHTTPClientInitializer(), "", token.NewStaticProvider("tok"))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if got := c.AuthStatus(time.Now()); got != (AuthStatus{}) {
+		t.Errorf("AuthStatus() = %+v, want zero for a provider without a cache", got)
+	}
+}
@@ -0,0 +1,72 @@
+package appleapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// compressionThreshold is the minimum request body size compressed by
+// WithRequestCompression. Smaller bodies gain little and cost CPU.
+const compressionThreshold = 1 << 10
+
+// bufferBody reads a non-replayable request body into memory so the request
+// carries an exact Content-Length and a GetBody for replaying it.
+// Bodies that are already replayable are left untouched.
+func bufferBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to buffer request body: %w", err)
+	}
+	setBody(req, data)
+	return nil
+}
+
+// setBody replaces the request body with data, keeping ContentLength and GetBody consistent.
+func setBody(req *http.Request, data []byte) {
+	req.ContentLength = int64(len(data))
+	if len(data) == 0 {
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// compressBody gzip-compresses a replayable request body of at least
+// compressionThreshold bytes and sets Content-Encoding. Bodies that already
+// have a Content-Encoding are left untouched.
+func compressBody(req *http.Request) error {
+	if req.GetBody == nil || req.ContentLength < compressionThreshold || req.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("failed to read request body for compression: %w", err)
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(zw, body); err != nil {
+		return fmt.Errorf("failed to compress request body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress request body: %w", err)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	setBody(req, buf.Bytes())
+	req.Header.Set("Content-Encoding", "gzip")
+	return nil
+}
@@ -0,0 +1,124 @@
+package appleapi
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClient_Do_BuffersBody(t *testing.T) {
+	const body = `{"message":"hello"}`
+
+	type received struct {
+		contentLength    int64
+		transferEncoding []string
+		body             string
+	}
+	var got received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = received{
+			contentLength:    r.ContentLength,
+			transferEncoding: r.TransferEncoding,
+			body:             string(b),
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	// io.NopCloser hides the concrete reader, so http.NewRequest cannot determine the length.
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, io.NopCloser(strings.NewReader(body)))
+	if req.ContentLength != 0 || req.GetBody != nil {
+		t.Fatal("expected a request with unknown length and no GetBody")
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if req.ContentLength != int64(len(body)) {
+		t.Errorf("ContentLength = %d, want %d", req.ContentLength, len(body))
+	}
+	if req.GetBody == nil {
+		t.Fatal("expected GetBody to be set")
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		t.Fatalf("GetBody failed: %v", err)
+	}
+	replayed, _ := io.ReadAll(rc)
+	if diff := cmp.Diff(body, string(replayed)); diff != "" {
+		t.Errorf("replayed body mismatch (-want +got):\n%s", diff)
+	}
+
+	want := received{contentLength: int64(len(body)), body: body}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(received{})); diff != "" {
+		t.Errorf("server received mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_Do_RequestCompression(t *testing.T) {
+	large := strings.Repeat(`{"name":"app"},`, 200)
+
+	type received struct {
+		Encoding string
+		Body     string
+	}
+	var got []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("server could not read gzip body: %v", err)
+				return
+			}
+			body = zr
+		}
+		b, _ := io.ReadAll(body)
+		got = append(got, received{r.Header.Get("Content-Encoding"), string(b)})
+		if len(got) == 1 && len(b) == len(large) {
+			w.WriteHeader(http.StatusServiceUnavailable) // exercise the replayed body
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithRequestCompression(),
+		WithRetry(2, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for _, body := range []string{large, `{"small":true}`} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, io.NopCloser(strings.NewReader(body)))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	want := []received{
+		{Encoding: "gzip", Body: large},
+		{Encoding: "gzip", Body: large},
+		{Encoding: "", Body: `{"small":true}`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("received bodies mismatch (-want +got):\n%s", diff)
+	}
+}
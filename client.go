@@ -2,21 +2,29 @@ package appleapi
 
 // Package appleapi provides a client for interacting with Apple APIs, handling JWT-based authentication.
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"slices"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/takimoto3/appleapi-core/token"
+	"golang.org/x/net/http/httpguts"
 	"golang.org/x/net/http2"
 )
 
 // OptionOrder defines the execution order for Client options.
-// Options are applied in ascending order of these constants.
+// Options are applied in ascending order of these constants; options with
+// the same order are applied in the order they were passed.
 type OptionOrder int
 
 const (
@@ -24,22 +32,72 @@ const (
 	Logger
 	Transport
 	ClientTimeout
-	ClientTrace // Depends on Logger being already set
+	ClientTrace   // Depends on Logger being already set
+	ContextLogger // Per-request logger extraction
+	LogLevel      // Depends on ClientTrace being already set
+	RequestSizeLogging
+	AllowPlaintext
+	ConnReuseWarning
+	TLSSessionCache // Depends on Transport being already set
+	DisableKeepAlives
+	TraceSequence
+	CookieJar
+	ResponseBuffering
+	ResponseInterceptor
+	TransportWrapper // Depends on Transport being already set
+	RootCAs          // Depends on Transport being already set
+	FailoverHosts
+	TraceSampling
+	BeforeRequest
+	MaxConcurrentStreams
+	Retry
+	RequestCompression
+	TraceHeaderLogging
+	MaxRetryDelay
+	RetryableStatusCodes
+	ConnectionStats
+	HostHeader
+	Backoff
+	DisableTrace // Must run after ClientTrace
+	DefaultRequestTimeout
+	AuthScheme
+	ResponseValidator
+	DevelopmentHost // Depends on Development being already set
+	DNSCache        // Depends on Transport being already set
+	StatusCodeMetrics
+	ConnErrorRetry
+	LogAttrsFromRequest
 )
 
+// configureHTTP2 enables HTTP/2 on a transport. It is a variable so tests
+// can simulate a configuration failure.
+var configureHTTP2 = http2.ConfigureTransports
+
 // HTTPClientInitializer is a function that returns a configured *http.Client.
 type HTTPClientInitializer func() (*http.Client, error)
 
-// DefaultHTTPClientInitializer returns a default HTTP client with TLS1.3 and HTTP/2 enabled.
+// defaultClientTimeout is the overall request timeout of the client returned
+// by DefaultHTTPClientInitializer.
+var defaultClientTimeout = 30 * time.Second
+
+// DefaultHTTPClientInitializer returns a default HTTP client with HTTP/2 enabled.
+// It uses the same TLS version bounds (TLS 1.2 to TLS 1.3) and ALPN
+// protocols ("h2" and "http/1.1") as DefaultConfig. Requests time out after
+// 30 seconds, including reading the response body; use WithClientTimeout to
+// change this, or WithClientTimeout(0) to disable it.
 func DefaultHTTPClientInitializer() HTTPClientInitializer {
 	return func() (*http.Client, error) {
 		// Clone the default transport to customize settings safely
 		tr := http.DefaultTransport.(*http.Transport).Clone()
-		tr.TLSClientConfig = &tls.Config{MaxVersion: tls.VersionTLS13} // Use TLS 1.3 only
-		tr.MaxIdleConnsPerHost = 100                                   // Max idle connections per host
-		tr.MaxConnsPerHost = 100                                       // Max total connections per host
-		tr.ForceAttemptHTTP2 = true                                    // Enable HTTP/2
-		return &http.Client{Transport: tr}, nil
+		tr.TLSClientConfig = &tls.Config{
+			MinVersion: defaultConfig.TLSMinVersion,
+			MaxVersion: defaultConfig.TLSMaxVersion,
+			NextProtos: slices.Clone(defaultConfig.NextProtos),
+		}
+		tr.MaxIdleConnsPerHost = 100 // Max idle connections per host
+		tr.MaxConnsPerHost = 100     // Max total connections per host
+		tr.ForceAttemptHTTP2 = true  // Enable HTTP/2
+		return &http.Client{Transport: tr, Timeout: defaultClientTimeout}, nil
 	}
 }
 
@@ -51,24 +109,112 @@ func ConfigureHTTPClientInitializer(cfg *HTTPConfig) HTTPClientInitializer {
 		if cfg.TLSConfig != nil {
 			tr.TLSClientConfig = cfg.TLSConfig.Clone()
 		}
+		if cfg.TLSMinVersion != 0 || cfg.TLSMaxVersion != 0 || cfg.TLSRenegotiation != tls.RenegotiateNever || len(cfg.NextProtos) > 0 {
+			if tr.TLSClientConfig == nil {
+				tr.TLSClientConfig = &tls.Config{}
+			}
+			if cfg.TLSMinVersion != 0 {
+				tr.TLSClientConfig.MinVersion = cfg.TLSMinVersion
+			}
+			if cfg.TLSMaxVersion != 0 {
+				tr.TLSClientConfig.MaxVersion = cfg.TLSMaxVersion
+			}
+			if cfg.TLSRenegotiation != tls.RenegotiateNever {
+				tr.TLSClientConfig.Renegotiation = cfg.TLSRenegotiation
+			}
+			if len(cfg.NextProtos) > 0 {
+				tr.TLSClientConfig.NextProtos = slices.Clone(cfg.NextProtos)
+			}
+		}
+		if cfg.TLSSessionCacheSize > 0 {
+			setTLSSessionCache(tr, cfg.TLSSessionCacheSize)
+		}
 		tr.MaxConnsPerHost = cfg.MaxConnsPerHost
 		tr.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+		tr.MaxIdleConns = cfg.MaxIdleConns
 		tr.IdleConnTimeout = cfg.IdleConnTimeout
-		tr.DialContext = (&net.Dialer{
+		tr.DisableKeepAlives = cfg.DisableKeepAlives
+		tr.MaxResponseHeaderBytes = cfg.MaxResponseHeaderBytes
+		dialer := &net.Dialer{
 			Timeout:   cfg.DialTimeout,
 			KeepAlive: cfg.KeepAlive,
-		}).DialContext
+		}
+		if cfg.LocalAddr != nil {
+			addr, ok := cfg.LocalAddr.(*net.TCPAddr)
+			if !ok {
+				return nil, fmt.Errorf("invalid LocalAddr: expected *net.TCPAddr, got %T", cfg.LocalAddr)
+			}
+			dialer.LocalAddr = addr
+		}
+		tr.DialContext = dialer.DialContext
+		if cfg.MaxConnLifetime > 0 {
+			tr.DialContext = dialWithLifetime(tr.DialContext, cfg.MaxConnLifetime)
+		}
 
-		tr2, err := http2.ConfigureTransports(tr)
+		tr2, err := configureHTTP2(tr)
 		if err != nil {
-			return nil, err
+			if !cfg.HTTP2Fallback {
+				return nil, err
+			}
+			// A non-nil empty map keeps net/http from enabling HTTP/2 on its own.
+			tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+			tr.ForceAttemptHTTP2 = false
+			if tr.TLSClientConfig != nil {
+				tr.TLSClientConfig.NextProtos = slices.DeleteFunc(tr.TLSClientConfig.NextProtos, func(p string) bool { return p == "h2" })
+			}
+			rt := &http2FallbackTransport{RoundTripper: withLifetime(tr, cfg.MaxConnLifetime), err: err}
+			return &http.Client{Transport: rt, Timeout: cfg.HTTPTimeout}, nil
 		}
 		tr2.ReadIdleTimeout = cfg.ReadIdleTimeout
 
-		return &http.Client{Transport: tr, Timeout: cfg.HTTPTimeout}, nil
+		return &http.Client{Transport: withLifetime(tr, cfg.MaxConnLifetime), Timeout: cfg.HTTPTimeout}, nil
+	}
+}
+
+// http2FallbackTransport marks the transport of a client that
+// ConfigureHTTPClientInitializer built with the HTTP/1.1 fallback, keeping
+// the HTTP/2 configuration error so NewClient and ApplyHTTPConfig can log it
+// through the client's logger.
+type http2FallbackTransport struct {
+	http.RoundTripper
+	err error
+}
+
+// Unwrap returns the wrapped transport.
+func (t *http2FallbackTransport) Unwrap() http.RoundTripper { return t.RoundTripper }
+
+// CloseIdleConnections closes idle connections of the wrapped transport.
+func (t *http2FallbackTransport) CloseIdleConnections() {
+	if ci, ok := t.RoundTripper.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// logHTTP2Fallback logs a warning if the client's transport was built with
+// the HTTP/1.1 fallback.
+func (c *Client) logHTTP2Fallback() {
+	rt := c.HTTPClient.Transport
+	for {
+		switch t := rt.(type) {
+		case *http2FallbackTransport:
+			c.log(context.Background(), c.Logger, slog.LevelWarn, "HTTP/2 configuration failed, falling back to HTTP/1.1", slog.Any("err", t.err))
+			return
+		case interface{ Unwrap() http.RoundTripper }:
+			rt = t.Unwrap()
+		default:
+			return
+		}
 	}
 }
 
+// Doer sends an HTTP request and returns its response. *Client implements
+// it; helpers that accept a Doer instead of a *Client can be given a fake in tests.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+var _ Doer = (*Client)(nil)
+
 // Client represents an HTTP client with Apple authentication support.
 type Client struct {
 	Host          string                 // Base URL for Apple API
@@ -77,6 +223,53 @@ type Client struct {
 	TokenProvider token.Provider         // Responsible for providing tokens
 	Logger        *slog.Logger           // Structured logger
 	Trace         *httptrace.ClientTrace // HTTP request trace hooks
+
+	traceFunc       func(*slog.Logger) *httptrace.ClientTrace   // Builds trace hooks for a given logger
+	contextLogger   func(context.Context) *slog.Logger          // Extracts a request-scoped logger
+	logAttrs        func(*http.Request) []slog.Attr             // Derives log attributes from each request
+	logLevel        slog.Leveler                                // Minimum level for client logs; nil logs everything
+	logSize         bool                                        // Log the approximate size of each request
+	plaintextOK     bool                                        // Allow requests over plaintext http://
+	warnNewConn     bool                                        // Warn when a request does not reuse a connection
+	gotFirstConn    atomic.Bool                                 // Set once the first connection has been obtained
+	sequenceLogs    bool                                        // Tag request logs and trace events with a sequence number
+	requestSeq      atomic.Uint64                               // Sequence number of the last request
+	bufferLimit     int64                                       // Maximum response body size buffered in memory; 0 disables buffering
+	interceptors    []func(*http.Response) error                // Run on every response in order
+	validators      []func(*http.Response) error                // Check every response after the interceptors, without access to the body
+	beforeHooks     []func(*http.Request) error                 // Run on every request in order before sending
+	wrappers        []func(http.RoundTripper) http.RoundTripper // Transport wrappers, reapplied by ApplyHTTPConfig
+	streams         chan struct{}                               // Slots for in-flight requests; nil means unlimited
+	authHeader      atomic.Pointer[bearerHeader]                // Authorization value for the last token
+	failoverHosts   []string                                    // Hosts tried in order when connecting fails
+	traceSample     uint64                                      // Trace 1 in traceSample requests; 0 or 1 traces all
+	traceCount      atomic.Uint64                               // Number of requests considered for tracing
+	retryAttempts   int                                         // Maximum attempts per request, including the first; 0 or 1 disables retries
+	retryBase       time.Duration                               // Initial backoff delay between attempts
+	retryMax        time.Duration                               // Upper bound on the backoff delay; 0 means uncapped
+	capRetryAfter   bool                                        // Apply retryMax to Retry-After delays as well
+	retryStatuses   []int                                       // Status codes retried; nil uses the default set
+	retryBackoff    BackoffStrategy                             // Computes the delay between attempts; nil uses ExponentialFullJitter
+	retryConnErrors bool                                        // Also retry requests that failed with a retryable connection error
+	connStats       *connStats                                  // Connection counters; nil when disabled
+	hostHeader      string                                      // Host header sent instead of the URL host; empty keeps the request's
+	compress        bool                                        // Gzip-compress request bodies of at least compressionThreshold bytes
+	logTraceIDs     bool                                        // Log trace propagation headers found on responses
+	reqTimeout      time.Duration                               // Deadline applied to requests whose context has none; 0 disables
+	authScheme      string                                      // Authorization scheme; empty means "Bearer"
+	devHost         string                                      // Host used in development mode; empty keeps Host unchanged
+	prodHost        string                                      // Host used outside development mode when devHost is set
+	metrics         requestMetrics                              // Request counters reported by MetricsHandler
+	freshMu         sync.Mutex                                  // Guards fresh and freshBase
+	fresh           http.RoundTripper                           // Transport for ContextForceNewConn requests; nil until first used
+	freshBase       *http.Transport                             // Base transport fresh was cloned from
+}
+
+// bearerHeader is an Authorization header value together with the token it
+// was built from, so the value is rebuilt only when the token changes.
+type bearerHeader struct {
+	token string
+	value string
 }
 
 // Option defines a configurable option for Client, including its execution order.
@@ -97,6 +290,53 @@ func WithDevelopment() Option {
 	}
 }
 
+// WithDevelopmentHost sets the base URL used in development mode, such as a
+// sandbox endpoint. The host passed to NewClient is then used only outside
+// development mode, and SetDevelopment switches between the two.
+func WithDevelopmentHost(host string) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && host != "" {
+				c.prodHost = c.Host
+				c.devHost = host
+				if c.Development {
+					c.Host = host
+				}
+			}
+		},
+		order: DevelopmentHost,
+	}
+}
+
+// WithH2C makes the client speak HTTP/2 with prior knowledge over cleartext
+// TCP (h2c) instead of TLS, for local testing against an h2c server. Like
+// WithTransport, it replaces the client's transport; wrappers still apply,
+// but options that tune an *http.Transport, such as WithDNSCache or
+// WithTLSSessionCache, do not. It only takes effect together with
+// WithDevelopment, and connections fail with ErrH2CNotDevelopment once
+// SetDevelopment(false) is called, so a token is never sent in cleartext to
+// a production host.
+func WithH2C() Option {
+	return Option{
+		f: func(c *Client) {
+			if c == nil || !c.Development {
+				return
+			}
+			c.HTTPClient.Transport = &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					if !c.Development {
+						return nil, ErrH2CNotDevelopment
+					}
+					var d net.Dialer
+					return d.DialContext(ctx, network, addr)
+				},
+			}
+		},
+		order: Transport,
+	}
+}
+
 // WithLogger sets a custom structured logger.
 func WithLogger(logger *slog.Logger) Option {
 	return Option{
@@ -121,7 +361,7 @@ func WithTransport(tr http.RoundTripper) Option {
 	}
 }
 
-// WithClientTimeout sets a custom HTTP client timeout.
+// WithClientTimeout sets a custom HTTP client timeout. Zero disables it.
 func WithClientTimeout(timeout time.Duration) Option {
 	return Option{
 		f: func(c *Client) {
@@ -140,6 +380,7 @@ func WithClientTrace(f func(*slog.Logger) *httptrace.ClientTrace) Option {
 			if c != nil {
 				if tr := f(c.Logger); tr != nil {
 					c.Trace = tr
+					c.traceFunc = f
 				}
 			}
 		},
@@ -147,6 +388,490 @@ func WithClientTrace(f func(*slog.Logger) *httptrace.ClientTrace) Option {
 	}
 }
 
+// WithAuthScheme sets the scheme of the Authorization header, so Do sends
+// "Authorization: <scheme> <token>". The default is "Bearer". A scheme that
+// is not a single HTTP token, such as one containing spaces, is ignored.
+func WithAuthScheme(scheme string) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && httpguts.ValidHeaderFieldName(scheme) {
+				c.authScheme = scheme
+			}
+		},
+		order: AuthScheme,
+	}
+}
+
+// WithDefaultRequestTimeout makes Do attach a deadline of d to requests whose
+// context has none, such as requests made with context.Background(). The
+// deadline also covers reading the response body. Requests that already carry
+// a deadline are left untouched. Non-positive values are ignored.
+func WithDefaultRequestTimeout(d time.Duration) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && d > 0 {
+				c.reqTimeout = d
+			}
+		},
+		order: DefaultRequestTimeout,
+	}
+}
+
+// WithDisableTrace turns off the client trace hooks, overriding any
+// WithClientTrace regardless of the order the options are passed in.
+func WithDisableTrace() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.Trace = nil
+				c.traceFunc = nil
+			}
+		},
+		order: DisableTrace,
+	}
+}
+
+// WithTraceSampling limits the client trace hooks to 1 in n requests, so
+// high request rates do not flood the logs; the other requests emit no trace
+// events. Request logs are not affected. Values of n below 2 trace every request.
+func WithTraceSampling(n int) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && n > 1 {
+				c.traceSample = uint64(n)
+			}
+		},
+		order: TraceSampling,
+	}
+}
+
+// WithContextLogger sets a function that extracts a request-scoped logger
+// from the request context. When it returns a non-nil logger, Do uses it
+// instead of Client.Logger for its logging and trace hooks.
+func WithContextLogger(extract func(context.Context) *slog.Logger) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && extract != nil {
+				c.contextLogger = extract
+			}
+		},
+		order: ContextLogger,
+	}
+}
+
+// WithLogAttrsFromRequest sets a function that derives log attributes from
+// each request, such as an operation name taken from the URL path. Do adds
+// them to its request log records and trace events. The function is called
+// before the Authorization header is set.
+func WithLogAttrsFromRequest(f func(*http.Request) []slog.Attr) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && f != nil {
+				c.logAttrs = f
+			}
+		},
+		order: LogAttrsFromRequest,
+	}
+}
+
+// WithLogLevel sets the minimum level for records logged by the client,
+// including the events of trace hooks set with WithClientTrace. Records below
+// the level are dropped before reaching the logger's handler. Pass a
+// *slog.LevelVar to adjust the threshold at runtime.
+func WithLogLevel(level slog.Leveler) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && level != nil {
+				c.logLevel = level
+				if c.traceFunc != nil {
+					// Rebuild the trace hooks so they write through the filter.
+					if tr := c.traceFunc(c.traceLogger(c.Logger)); tr != nil {
+						c.Trace = tr
+					}
+				}
+			}
+		},
+		order: LogLevel,
+	}
+}
+
+// WithRequestSizeLogging enables logging of the approximate number of bytes
+// written for each request (header fields plus body length).
+func WithRequestSizeLogging() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.logSize = true
+			}
+		},
+		order: RequestSizeLogging,
+	}
+}
+
+// WithTraceHeaderLogging adds the trace propagation headers of each response
+// (traceparent, b3 and X-B3-TraceId) to the request log record, so requests
+// can be correlated with traces recorded by Apple or an intermediary. The
+// headers are only read; nothing is propagated.
+func WithTraceHeaderLogging() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.logTraceIDs = true
+			}
+		},
+		order: TraceHeaderLogging,
+	}
+}
+
+// WithConnStats enables connection counters, read with ConnStats, that
+// record how many requests used a new or a reused connection and the time
+// spent in TLS handshakes, for capacity planning.
+func WithConnStats() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.connStats = &connStats{}
+			}
+		},
+		order: ConnectionStats,
+	}
+}
+
+// ConnStats returns the connection counters collected since the client was
+// created. It returns the zero value unless WithConnStats is set.
+func (c *Client) ConnStats() ConnStats {
+	if c.connStats == nil {
+		return ConnStats{}
+	}
+	return c.connStats.snapshot()
+}
+
+// WithAllowPlaintext permits requests to http:// URLs outside development mode.
+// By default Do rejects them with ErrPlaintextHTTP so bearer tokens are never
+// sent unencrypted by accident.
+func WithAllowPlaintext() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.plaintextOK = true
+			}
+		},
+		order: AllowPlaintext,
+	}
+}
+
+// WithConnReuseWarning logs a warning whenever a request after the first one
+// is sent on a new connection instead of reusing an existing one. Apple
+// recommends keeping long-lived connections, so frequent warnings point to
+// connection churn (e.g. disabled keep-alives or a too-low MaxConnsPerHost).
+func WithConnReuseWarning() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.warnNewConn = true
+			}
+		},
+		order: ConnReuseWarning,
+	}
+}
+
+// WithTLSSessionCache installs an LRU TLS session cache of the given capacity
+// on the client's transport so repeated connections resume TLS sessions.
+// It has no effect unless the transport is an *http.Transport.
+func WithTLSSessionCache(size int) Option {
+	return Option{
+		f: func(c *Client) {
+			if c == nil || size <= 0 {
+				return
+			}
+			if tr := c.BaseTransport(); tr != nil {
+				setTLSSessionCache(tr, size)
+			}
+		},
+		order: TLSSessionCache,
+	}
+}
+
+// WithDNSCache caches host name resolutions of the transport's dialer for
+// ttl, so frequent new connections do not each wait for a DNS lookup.
+// Names that do not exist are cached as well; other lookup failures are not.
+// The resolved addresses are dialed in order until one succeeds. It has no
+// effect unless the transport is an *http.Transport, and values of ttl of
+// zero or less are ignored.
+func WithDNSCache(ttl time.Duration) Option {
+	return Option{
+		f: func(c *Client) {
+			if c == nil || ttl <= 0 {
+				return
+			}
+			if tr := c.BaseTransport(); tr != nil {
+				dial := tr.DialContext
+				if dial == nil {
+					dial = (&net.Dialer{}).DialContext
+				}
+				tr.DialContext = dialWithDNSCache(dial, newDNSCache(net.DefaultResolver, ttl))
+			}
+		},
+		order: DNSCache,
+	}
+}
+
+// WithAppleRootCAs makes the client trust only the embedded root
+// certificates returned by AppleRootCAs instead of the system trust store.
+// It has no effect unless the transport is an *http.Transport.
+func WithAppleRootCAs() Option {
+	return Option{
+		f: func(c *Client) {
+			if c == nil {
+				return
+			}
+			if tr := c.BaseTransport(); tr != nil {
+				if tr.TLSClientConfig == nil {
+					tr.TLSClientConfig = &tls.Config{}
+				} else {
+					tr.TLSClientConfig = tr.TLSClientConfig.Clone()
+				}
+				tr.TLSClientConfig.RootCAs = AppleRootCAs()
+			}
+		},
+		order: RootCAs,
+	}
+}
+
+// WithCookieJar attaches a cookie jar to the underlying HTTP client so
+// cookies set by the server are replayed on later requests. By default no
+// jar is used.
+func WithCookieJar(jar http.CookieJar) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && jar != nil {
+				c.HTTPClient.Jar = jar
+			}
+		},
+		order: CookieJar,
+	}
+}
+
+// WithResponseBodyBuffering reads response bodies of up to limit bytes into
+// memory so that every consumer (response interceptors and the caller) can
+// read the complete body; the body is rewound before each of them. Bodies
+// larger than limit are passed through unbuffered.
+func WithResponseBodyBuffering(limit int64) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && limit > 0 {
+				c.bufferLimit = limit
+			}
+		},
+		order: ResponseBuffering,
+	}
+}
+
+// WithResponseInterceptor adds a function that is run by Do on every
+// response, in the order the interceptors were added. An interceptor that
+// reads the body consumes it for later readers unless response body
+// buffering is enabled. A returned error closes the body and is returned
+// from Do.
+func WithResponseInterceptor(f func(*http.Response) error) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && f != nil {
+				c.interceptors = append(c.interceptors, f)
+			}
+		},
+		order: ResponseInterceptor,
+	}
+}
+
+// WithResponseValidator adds a function that is run by Do on every response
+// after the response interceptors, in the order the validators were added.
+// Validators only inspect the response: they receive a copy with its own
+// headers and an empty body, so the caller's response is never changed.
+// A returned error closes the body and is returned from Do.
+func WithResponseValidator(f func(*http.Response) error) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && f != nil {
+				c.validators = append(c.validators, f)
+			}
+		},
+		order: ResponseValidator,
+	}
+}
+
+// WithBeforeRequest adds a function that is run by Do on every request, in
+// the order the hooks were added, after the Authorization header is set and
+// just before the request is sent. Hooks may read or modify the request,
+// including the bearer token. A returned error aborts the request and is
+// returned from Do.
+func WithBeforeRequest(f func(*http.Request) error) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && f != nil {
+				c.beforeHooks = append(c.beforeHooks, f)
+			}
+		},
+		order: BeforeRequest,
+	}
+}
+
+// WithMaxConcurrentStreams limits the number of requests the client has in
+// flight at once to n, to stay within the concurrent stream limit Apple sets
+// for an HTTP/2 connection instead of queueing or opening extra connections.
+// A request holds its slot until its response body is read to the end or
+// closed, so callers must close response bodies. Do waits for a free slot and
+// returns the context error if the request context is done first.
+func WithMaxConcurrentStreams(n int) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && n > 0 {
+				c.streams = make(chan struct{}, n)
+			}
+		},
+		order: MaxConcurrentStreams,
+	}
+}
+
+// WithRequestCompression gzip-compresses request bodies of 1 KiB or more and
+// sets "Content-Encoding: gzip", for endpoints that accept compressed
+// uploads. Bodies that already carry a Content-Encoding are sent as they
+// are. The compressed body is replayable, so retries and failover resend it.
+func WithRequestCompression() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.compress = true
+			}
+		},
+		order: RequestCompression,
+	}
+}
+
+// WithTransportWrapper wraps the client's current transport with wrap, for
+// example otelhttp.NewTransport. Unlike WithTransport, which replaces the
+// transport, the wrapped transport keeps its pooling and HTTP/2 settings.
+// BaseTransport and CloseIdleConnections still reach the wrapped transport.
+func WithTransportWrapper(wrap func(http.RoundTripper) http.RoundTripper) Option {
+	return Option{
+		f: func(c *Client) {
+			if c == nil || wrap == nil {
+				return
+			}
+			c.wrappers = append(c.wrappers, wrap)
+			c.wrapTransport(wrap)
+		},
+		order: TransportWrapper,
+	}
+}
+
+// wrapTransport wraps the client's current transport with wrap.
+func (c *Client) wrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	base := c.HTTPClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if rt := wrap(base); rt != nil {
+		c.HTTPClient.Transport = &wrappedTransport{RoundTripper: rt, base: base}
+	}
+}
+
+// WithHostHeader sends host as the Host header of every request while the
+// connection is still made to the URL host, for example to reach a CDN edge
+// by address while presenting the logical hostname. The TLS server name
+// (SNI) is not affected: it stays the URL host unless set with
+// HTTPConfig.TLSConfig.ServerName, so set both when the edge expects the
+// logical name during the handshake too.
+func WithHostHeader(host string) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.hostHeader = host
+			}
+		},
+		order: HostHeader,
+	}
+}
+
+// WithFailoverHosts sets alternate hosts (host[:port] or URLs) that Do tries
+// in order when a connection to the request's host cannot be established.
+// The request is resent with only the URL host rewritten. Failover happens
+// only when connecting fails, so the server never saw the earlier attempt;
+// error responses such as 4xx or 5xx are returned as they are.
+func WithFailoverHosts(hosts ...string) Option {
+	return Option{
+		f: func(c *Client) {
+			if c == nil {
+				return
+			}
+			for _, h := range hosts {
+				if h = failoverHost(h); h != "" {
+					c.failoverHosts = append(c.failoverHosts, h)
+				}
+			}
+		},
+		order: FailoverHosts,
+	}
+}
+
+// wrappedTransport is a RoundTripper produced by WithTransportWrapper that
+// remembers the transport it wraps.
+type wrappedTransport struct {
+	http.RoundTripper
+	base http.RoundTripper
+}
+
+// Unwrap returns the wrapped transport.
+func (t *wrappedTransport) Unwrap() http.RoundTripper { return t.base }
+
+// CloseIdleConnections closes idle connections of the wrapped transport.
+func (t *wrappedTransport) CloseIdleConnections() {
+	if ci, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// WithTraceSequence tags the request log and trace events of each request
+// with a per-client sequence number (req=N), so the events of a single
+// request can be correlated. Trace hooks set with WithClientTrace are
+// rebuilt for every request with the tagged logger.
+func WithTraceSequence() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.sequenceLogs = true
+			}
+		},
+		order: TraceSequence,
+	}
+}
+
+// WithDisableKeepAlives forces a new connection for every request, which
+// helps reproduce connection-setup issues. It is intended for debugging and
+// has no effect unless the transport is an *http.Transport.
+func WithDisableKeepAlives() Option {
+	return Option{
+		f: func(c *Client) {
+			if c == nil {
+				return
+			}
+			if tr := c.BaseTransport(); tr != nil {
+				tr.DisableKeepAlives = true
+			}
+		},
+		order: DisableKeepAlives,
+	}
+}
+
+// setTLSSessionCache installs an LRU client session cache of the given size on tr.
+func setTLSSessionCache(tr *http.Transport, size int) {
+	if tr.TLSClientConfig == nil {
+		tr.TLSClientConfig = &tls.Config{}
+	} else {
+		tr.TLSClientConfig = tr.TLSClientConfig.Clone()
+	}
+	tr.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(size)
+}
+
 // NewClient creates a new Client with a custom HTTP initializer and options.
 func NewClient(initializer HTTPClientInitializer, host string, tp token.Provider, opts ...Option) (*Client, error) {
 	cli, err := initializer()
@@ -160,13 +885,15 @@ func NewClient(initializer HTTPClientInitializer, host string, tp token.Provider
 		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
 	}
 
-	// Sort options by their order and apply them
-	sort.Slice(opts, func(i, j int) bool {
+	// Sort options by their order and apply them.
+	// Options sharing the same order are applied in the order they were passed.
+	sort.SliceStable(opts, func(i, j int) bool {
 		return opts[i].order < opts[j].order
 	})
 	for _, opt := range opts {
 		opt.f(c)
 	}
+	c.logHTTP2Fallback()
 
 	return c, nil
 }
@@ -174,18 +901,257 @@ func NewClient(initializer HTTPClientInitializer, host string, tp token.Provider
 // CloseIdleConnections closes idle connections in the HTTP client.
 func (c *Client) CloseIdleConnections() {
 	c.HTTPClient.CloseIdleConnections()
+	c.freshMu.Lock()
+	defer c.freshMu.Unlock()
+	if ci, ok := c.fresh.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// SetDevelopment turns development mode on or off. It also selects the host
+// set with WithDevelopmentHost, if any, and switches a token provider that
+// supports it, such as *token.TokenProvider, to the matching environment.
+// SetDevelopment must not be called concurrently with Do.
+func (c *Client) SetDevelopment(dev bool) {
+	c.Development = dev
+	if c.devHost != "" {
+		if dev {
+			c.Host = c.devHost
+		} else {
+			c.Host = c.prodHost
+		}
+	}
+	if p, ok := c.TokenProvider.(interface{ SetDevelopment(bool) }); ok {
+		p.SetDevelopment(dev)
+	}
+}
+
+// ApplyHTTPConfig rebuilds the client's transport and timeout from cfg, as
+// ConfigureHTTPClientInitializer does, and swaps them in. Wrappers added with
+// WithTransportWrapper are applied again on top of the new transport, and
+// idle connections of the old transport are closed. Other options that
+// modify the transport, such as WithTLSSessionCache, are not carried over;
+// use the corresponding HTTPConfig fields instead. ApplyHTTPConfig must not
+// be called concurrently with Do.
+func (c *Client) ApplyHTTPConfig(cfg *HTTPConfig) error {
+	if cfg == nil {
+		return errors.New("nil HTTP config")
+	}
+	cli, err := ConfigureHTTPClientInitializer(cfg)()
+	if err != nil {
+		return err
+	}
+	old := c.HTTPClient.Transport
+	c.HTTPClient.Transport = cli.Transport
+	c.HTTPClient.Timeout = cli.Timeout
+	for _, wrap := range c.wrappers {
+		c.wrapTransport(wrap)
+	}
+	c.logHTTP2Fallback()
+	if ci, ok := old.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+	return nil
+}
+
+// Flush waits for background work started by the client or its token
+// provider, such as a background token refresh, to complete. It returns
+// ctx.Err() if ctx is done first and is a no-op when no async work is configured.
+func (c *Client) Flush(ctx context.Context) error {
+	if f, ok := c.TokenProvider.(interface{ Flush(context.Context) error }); ok {
+		return f.Flush(ctx)
+	}
+	return nil
 }
 
 // Do sends an HTTP request with a Bearer token and optional HTTP trace.
-func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	if c.Trace != nil {
-		req = req.WithContext(httptrace.WithClientTrace(req.Context(), c.Trace))
+// A request body that cannot be replayed is buffered so the request carries
+// an exact Content-Length and a GetBody, unless the request context was
+// marked with ContextStreamingBody.
+// Outside development mode, requests to http:// URLs fail with ErrPlaintextHTTP
+// unless WithAllowPlaintext is set.
+func (c *Client) Do(req *http.Request) (resp *http.Response, err error) {
+	c.metrics.requests.Add(1)
+	defer func() {
+		if err != nil {
+			c.metrics.errors.Add(1)
+		}
+	}()
+	if c.reqTimeout <= 0 {
+		return c.do(req)
+	}
+	if _, ok := req.Context().Deadline(); ok {
+		return c.do(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), c.reqTimeout)
+	resp, err = c.do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+	// Keep the deadline alive until the caller has consumed the body.
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: cancel}
+	return resp, nil
+}
+
+// do implements Do once the default request deadline has been applied.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" && !c.Development && !c.plaintextOK {
+		return nil, ErrPlaintextHTTP
+	}
+	ctx := req.Context()
+	logger := c.requestLogger(ctx)
+	if c.sequenceLogs {
+		logger = logger.With(slog.Uint64("req", c.requestSeq.Add(1)))
+	}
+	if c.logAttrs != nil {
+		if attrs := c.logAttrs(req); len(attrs) > 0 {
+			logger = slog.New(logger.Handler().WithAttrs(attrs))
+		}
+	}
+
+	trace := c.requestTrace(ctx, logger)
+	traceCtx := ctx
+	if trace != nil {
+		traceCtx = httptrace.WithClientTrace(traceCtx, trace)
+	}
+	var size *requestSize
+	if c.warnNewConn {
+		traceCtx = httptrace.WithClientTrace(traceCtx, c.connReuseTrace(ctx, logger))
 	}
-	bearer, err := c.TokenProvider.GetToken(time.Now())
+	if c.logSize {
+		size = &requestSize{}
+		traceCtx = httptrace.WithClientTrace(traceCtx, size.trace())
+	}
+	if c.connStats != nil {
+		traceCtx = httptrace.WithClientTrace(traceCtx, c.connStats.trace())
+	}
+	if traceCtx != ctx {
+		req = req.WithContext(traceCtx)
+	}
+	if !streamingBody(ctx) {
+		if err := bufferBody(req); err != nil {
+			return nil, err
+		}
+		if c.compress {
+			if err := compressBody(req); err != nil {
+				return nil, err
+			}
+		}
+	}
+	bearer, err := getToken(ctx, c.TokenProvider)
 	if err != nil {
+		c.log(ctx, logger, slog.LevelError, "Failed to get token", slog.Any("err", err))
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
+	req.Header.Set("Authorization", c.authorization(bearer))
+	if c.hostHeader != "" {
+		req.Host = c.hostHeader
+	}
+	for _, hook := range c.beforeHooks {
+		if err := hook(req); err != nil {
+			return nil, err
+		}
+	}
 
-	return c.HTTPClient.Do(req)
+	release, err := c.acquireStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	req, resp, err := c.sendWithRetry(ctx, logger, req)
+	if err != nil {
+		release()
+		c.log(ctx, logger, slog.LevelDebug, "Request failed",
+			slog.String("method", req.Method),
+			slog.String("url", req.URL.String()),
+			slog.Any("err", err),
+		)
+		return resp, err
+	}
+	c.metrics.observe(resp.StatusCode)
+	c.metrics.observeSizes(req, resp)
+	if c.streams != nil {
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	}
+	if c.enabled(ctx, logger, slog.LevelDebug) {
+		attrs := []slog.Attr{
+			slog.String("method", req.Method),
+			slog.String("url", req.URL.String()),
+			slog.Int("status", resp.StatusCode),
+			slog.Duration("elapsed", time.Since(start)),
+		}
+		if size != nil {
+			attrs = append(attrs, slog.Int64("requestBytes", size.bytes(req)))
+		}
+		if c.logTraceIDs {
+			attrs = appendTraceHeaders(attrs, resp.Header)
+		}
+		c.log(ctx, logger, slog.LevelDebug, "Request completed", attrs...)
+	}
+
+	if err := c.processResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// authorization returns the Authorization header value for token. The value
+// is cached so that requests reusing the same token do not rebuild it.
+func (c *Client) authorization(token string) string {
+	if h := c.authHeader.Load(); h != nil && h.token == token {
+		return h.value
+	}
+	scheme := c.authScheme
+	if scheme == "" {
+		scheme = "Bearer"
+	}
+	h := &bearerHeader{token: token, value: scheme + " " + token}
+	c.authHeader.Store(h)
+	return h.value
+}
+
+// enabled reports whether a record at level would be emitted to logger.
+// It lets hot paths skip building attributes for records that are dropped.
+func (c *Client) enabled(ctx context.Context, logger *slog.Logger, level slog.Level) bool {
+	if c.logLevel != nil && level < c.logLevel.Level() {
+		return false
+	}
+	return logger.Enabled(ctx, level)
+}
+
+// log emits a record to logger unless level is below the configured threshold.
+func (c *Client) log(ctx context.Context, logger *slog.Logger, level slog.Level, msg string, attrs ...slog.Attr) {
+	if c.logLevel != nil && level < c.logLevel.Level() {
+		return
+	}
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// BaseTransport returns the client's underlying *http.Transport, looking
+// through wrappers added with WithTransportWrapper. It returns nil if the
+// client uses another kind of RoundTripper.
+func (c *Client) BaseTransport() *http.Transport {
+	rt := c.HTTPClient.Transport
+	for {
+		switch t := rt.(type) {
+		case *http.Transport:
+			return t
+		case interface{ Unwrap() http.RoundTripper }:
+			rt = t.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// requestLogger returns the logger for a request, preferring the one
+// extracted from ctx and falling back to Client.Logger.
+func (c *Client) requestLogger(ctx context.Context) *slog.Logger {
+	if c.contextLogger != nil {
+		if l := c.contextLogger(ctx); l != nil {
+			return l
+		}
+	}
+	return c.Logger
 }
@@ -2,9 +2,24 @@ package appleapi
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func TestConfigureHTTPClientInitializer(t *testing.T) {
@@ -45,3 +60,712 @@ func TestConfigureHTTPClientInitializer(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 }
+
+func TestTLSSessionCache(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TLSSessionCacheSize = 64
+
+	tests := map[string]struct {
+		init      HTTPClientInitializer
+		opts      []Option
+		wantCache bool
+	}{
+		"disabled by default": {
+			init:      DefaultHTTPClientInitializer(),
+			wantCache: false,
+		},
+		"from config": {
+			init:      ConfigureHTTPClientInitializer(&cfg),
+			wantCache: true,
+		},
+		"from option": {
+			init:      DefaultHTTPClientInitializer(),
+			opts:      []Option{WithTLSSessionCache(64)},
+			wantCache: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(tt.init, "https://example.com", &MockTokenProvider{token: "tok"}, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			tr := c.BaseTransport()
+			if tr == nil || tr.TLSClientConfig == nil {
+				t.Fatal("expected an *http.Transport with a TLS config")
+			}
+			if got := tr.TLSClientConfig.ClientSessionCache != nil; got != tt.wantCache {
+				t.Errorf("session cache installed = %v, want %v", got, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestDisableKeepAlives(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.DisableKeepAlives = true
+
+	tests := map[string]struct {
+		init HTTPClientInitializer
+		opts []Option
+	}{
+		"from config": {
+			init: ConfigureHTTPClientInitializer(&cfg),
+		},
+		"from option": {
+			init: DefaultHTTPClientInitializer(),
+			opts: []Option{WithDisableKeepAlives()},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			var reused []bool
+			opts := append([]Option{
+				WithAllowPlaintext(),
+				WithClientTrace(func(*slog.Logger) *httptrace.ClientTrace {
+					return &httptrace.ClientTrace{
+						GotConn: func(info httptrace.GotConnInfo) {
+							mu.Lock()
+							defer mu.Unlock()
+							reused = append(reused, info.Reused)
+						},
+					}
+				}),
+			}, tt.opts...)
+
+			c, err := NewClient(tt.init, srv.URL, &MockTokenProvider{token: "tok"}, opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			if !c.BaseTransport().DisableKeepAlives {
+				t.Fatal("expected DisableKeepAlives on the transport")
+			}
+
+			for range 3 {
+				req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do failed: %v", err)
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if diff := cmp.Diff([]bool{false, false, false}, reused); diff != "" {
+				t.Errorf("reused mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMaxConnLifetime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		lifetime time.Duration
+		want     []bool
+	}{
+		"unlimited": {lifetime: 0, want: []bool{false, true}},
+		"expired":   {lifetime: 50 * time.Millisecond, want: []bool{false, false}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MaxConnLifetime = tt.lifetime
+
+			var mu sync.Mutex
+			var reused []bool
+			c, err := NewClient(ConfigureHTTPClientInitializer(&cfg), srv.URL, &MockTokenProvider{token: "tok"},
+				WithAllowPlaintext(),
+				WithClientTrace(func(*slog.Logger) *httptrace.ClientTrace {
+					return &httptrace.ClientTrace{
+						GotConn: func(info httptrace.GotConnInfo) {
+							mu.Lock()
+							defer mu.Unlock()
+							reused = append(reused, info.Reused)
+						},
+					}
+				}),
+			)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			for i := range 2 {
+				if i > 0 {
+					time.Sleep(150 * time.Millisecond)
+				}
+				req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do failed: %v", err)
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if diff := cmp.Diff(tt.want, reused); diff != "" {
+				t.Errorf("reused mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMaxConnLifetime_InFlight(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		io.WriteString(w, r.Proto)
+	})
+
+	tests := map[string]struct {
+		tls   bool
+		proto string
+	}{
+		"http1": {proto: "HTTP/1.1"},
+		"http2": {tls: true, proto: "HTTP/2.0"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(handler)
+			cfg := DefaultConfig()
+			cfg.MaxConnLifetime = 50 * time.Millisecond
+			if tt.tls {
+				srv.EnableHTTP2 = true
+				srv.StartTLS()
+				roots := x509.NewCertPool()
+				roots.AddCert(srv.Certificate())
+				cfg.TLSConfig = &tls.Config{RootCAs: roots}
+			} else {
+				srv.Start()
+			}
+			defer srv.Close()
+
+			var mu sync.Mutex
+			var reused []bool
+			c, err := NewClient(ConfigureHTTPClientInitializer(&cfg), srv.URL, &MockTokenProvider{token: "tok"},
+				WithAllowPlaintext(),
+				WithClientTrace(func(*slog.Logger) *httptrace.ClientTrace {
+					return &httptrace.ClientTrace{
+						GotConn: func(info httptrace.GotConnInfo) {
+							mu.Lock()
+							defer mu.Unlock()
+							reused = append(reused, info.Reused)
+						},
+					}
+				}),
+			)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			// The lifetime expires while the slow request is running; it must
+			// still complete, and the next request must use a new connection.
+			for _, path := range []string{"/slow", "/"} {
+				req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do %s failed: %v", path, err)
+				}
+				body, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil || string(body) != tt.proto {
+					t.Fatalf("Do %s body = %q, %v; want %q", path, body, err, tt.proto)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if diff := cmp.Diff([]bool{false, false}, reused); diff != "" {
+				t.Errorf("reused mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDialWithLifetime_Unused(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	dial := dialWithLifetime(func(context.Context, string, string) (net.Conn, error) {
+		return client, nil
+	}, 10*time.Millisecond)
+
+	conn, err := dial(context.Background(), "tcp", "example.com:443")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	// No request ever ran on the connection, so it is idle and must be
+	// closed on expiry.
+	time.Sleep(100 * time.Millisecond)
+	conn.SetWriteDeadline(time.Now().Add(time.Second)) // the peer never reads
+	if _, err := conn.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Write error = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestLifetimeTransport_Release(t *testing.T) {
+	newConn := func() *lifetimeConn {
+		client, server := net.Pipe()
+		t.Cleanup(func() { client.Close(); server.Close() })
+		return &lifetimeConn{Conn: client, timer: time.NewTimer(time.Hour)}
+	}
+
+	tests := map[string]struct {
+		conns int
+		err   error
+	}{
+		"response": {conns: 1},
+		"retried":  {conns: 2},
+		"error":    {conns: 2, err: errors.New("round trip failed")},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var conns []*lifetimeConn
+			tr := &lifetimeTransport{base: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				// Each connection attempt reports GotConn, as a transport
+				// retrying the request does.
+				for range tt.conns {
+					lc := newConn()
+					conns = append(conns, lc)
+					httptrace.ContextClientTrace(req.Context()).GotConn(httptrace.GotConnInfo{Conn: lc})
+				}
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+			})}
+
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			resp, err := tr.RoundTrip(req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("RoundTrip error = %v, want %v", err, tt.err)
+			}
+			if resp != nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			for i, lc := range conns {
+				if lc.active != 0 {
+					t.Errorf("conn %d: active = %d, want 0", i, lc.active)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxResponseHeaderBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Large", strings.Repeat("a", 4<<10))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		limit   int64
+		wantErr bool
+	}{
+		"within limit": {limit: 8 << 10},
+		"exceeded":     {limit: 1 << 10, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MaxResponseHeaderBytes = tt.limit
+			c, err := NewClient(ConfigureHTTPClientInitializer(&cfg), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.Do(req)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "header") {
+					t.Errorf("Do error = %v, want a response header size error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestLocalAddr(t *testing.T) {
+	// Any 127.0.0.0/8 address is local on Linux; skip where only 127.0.0.1 is configured.
+	probe, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("127.0.0.2 is not available: %v", err)
+	}
+	probe.Close()
+
+	var remote string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+	}))
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.LocalAddr = &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}
+	c, err := NewClient(ConfigureHTTPClientInitializer(&cfg), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	host, _, _ := net.SplitHostPort(remote)
+	if host != "127.0.0.2" {
+		t.Errorf("server saw connection from %q, want 127.0.0.2", host)
+	}
+}
+
+func TestLocalAddr_InvalidType(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LocalAddr = &net.UDPAddr{IP: net.ParseIP("127.0.0.1")}
+	_, err := ConfigureHTTPClientInitializer(&cfg)()
+	if err == nil || !strings.Contains(err.Error(), "*net.UDPAddr") {
+		t.Errorf("initializer error = %v, want an invalid LocalAddr error", err)
+	}
+}
+
+func TestHTTP2Fallback(t *testing.T) {
+	orig := configureHTTP2
+	configureHTTP2 = func(*http.Transport) (*http2.Transport, error) {
+		return nil, errors.New("protocol https already registered")
+	}
+	t.Cleanup(func() { configureHTTP2 = orig })
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	t.Run("fails by default", func(t *testing.T) {
+		cfg := DefaultConfig()
+		if _, err := ConfigureHTTPClientInitializer(&cfg)(); err == nil {
+			t.Fatal("expected the HTTP/2 configuration error")
+		}
+	})
+
+	t.Run("falls back to HTTP/1.1", func(t *testing.T) {
+		h := newRecordHandler()
+		cfg := DefaultConfig()
+		cfg.HTTP2Fallback = true
+		cfg.TLSConfig = &tls.Config{RootCAs: roots}
+		c, err := NewClient(ConfigureHTTPClientInitializer(&cfg), srv.URL, &MockTokenProvider{token: "tok"}, WithLogger(slog.New(h)))
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		if _, ok := findRecord(h.Records(), "HTTP/2 configuration failed, falling back to HTTP/1.1"); !ok {
+			t.Errorf("missing fallback warning, got %v", h.Messages())
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "HTTP/1.1" {
+			t.Errorf("server saw protocol %q, want HTTP/1.1", body)
+		}
+	})
+
+	t.Run("warning filtered by log level", func(t *testing.T) {
+		h := newRecordHandler()
+		cfg := DefaultConfig()
+		cfg.HTTP2Fallback = true
+		if _, err := NewClient(ConfigureHTTPClientInitializer(&cfg), srv.URL, &MockTokenProvider{token: "tok"},
+			WithLogger(slog.New(h)), WithLogLevel(slog.LevelError)); err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		if msgs := h.Messages(); len(msgs) != 0 {
+			t.Errorf("expected no log output, got %v", msgs)
+		}
+	})
+}
+
+func TestWithH2C(t *testing.T) {
+	srv := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tlsUsed := "plaintext"
+		if r.TLS != nil {
+			tlsUsed = "tls"
+		}
+		io.WriteString(w, r.Proto+" "+tlsUsed)
+	}), &http2.Server{}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		opts []Option
+		want string
+	}{
+		"development":         {opts: []Option{WithDevelopment(), WithH2C()}, want: "HTTP/2.0 plaintext"},
+		"outside development": {opts: []Option{WithAllowPlaintext(), WithH2C()}, want: "HTTP/1.1 plaintext"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != tt.want {
+				t.Errorf("server saw %q, want %q", body, tt.want)
+			}
+		})
+	}
+
+	// Leaving development mode stops new h2c connections.
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithDevelopment(), WithAllowPlaintext(), WithH2C())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	c.SetDevelopment(false)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if _, err := c.Do(req); !errors.Is(err, ErrH2CNotDevelopment) {
+		t.Errorf("Do error = %v, want %v", err, ErrH2CNotDevelopment)
+	}
+}
+
+func TestClient_EffectiveConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxConnsPerHost = 7
+	cfg.MaxIdleConnsPerHost = 3
+	cfg.MaxIdleConns = 11
+	cfg.IdleConnTimeout = 42 * time.Second
+	cfg.HTTPTimeout = 12 * time.Second
+	cfg.DisableKeepAlives = true
+
+	c, err := NewClient(ConfigureHTTPClientInitializer(&cfg), "https://example.com", &MockTokenProvider{token: "tok"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	got := c.EffectiveConfig()
+	want := HTTPConfig{
+		HTTPTimeout:            cfg.HTTPTimeout,
+		MaxConnsPerHost:        cfg.MaxConnsPerHost,
+		MaxIdleConnsPerHost:    cfg.MaxIdleConnsPerHost,
+		MaxIdleConns:           cfg.MaxIdleConns,
+		IdleConnTimeout:        cfg.IdleConnTimeout,
+		DisableKeepAlives:      cfg.DisableKeepAlives,
+		MaxResponseHeaderBytes: cfg.MaxResponseHeaderBytes,
+		TLSMinVersion:          cfg.TLSMinVersion,
+		TLSMaxVersion:          cfg.TLSMaxVersion,
+		NextProtos:             cfg.NextProtos,
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(HTTPConfig{}, "TLSConfig")); diff != "" {
+		t.Errorf("EffectiveConfig mismatch (-want +got):\n%s", diff)
+	}
+
+	// Options applied after the initializer are reflected too.
+	c, err = NewClient(ConfigureHTTPClientInitializer(&cfg), "https://example.com", &MockTokenProvider{token: "tok"},
+		WithClientTimeout(3*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if got := c.EffectiveConfig().HTTPTimeout; got != 3*time.Second {
+		t.Errorf("HTTPTimeout = %v, want %v", got, 3*time.Second)
+	}
+}
+
+func TestClient_ApplyHTTPConfig(t *testing.T) {
+	var wrapped int
+	c, err := NewClient(DefaultHTTPClientInitializer(), "https://example.com", &MockTokenProvider{token: "tok"},
+		WithTransportWrapper(func(rt http.RoundTripper) http.RoundTripper {
+			wrapped++
+			return roundTripperFunc(rt.RoundTrip)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	old := c.BaseTransport()
+
+	cfg := DefaultConfig()
+	cfg.MaxConnsPerHost = 5
+	cfg.MaxIdleConnsPerHost = 2
+	cfg.IdleConnTimeout = 10 * time.Second
+	cfg.HTTPTimeout = 20 * time.Second
+	if err := c.ApplyHTTPConfig(&cfg); err != nil {
+		t.Fatalf("ApplyHTTPConfig failed: %v", err)
+	}
+
+	if c.BaseTransport() == old {
+		t.Fatal("expected the transport to be replaced")
+	}
+	if wrapped != 2 {
+		t.Errorf("wrapper applied %d times, want 2", wrapped)
+	}
+	got := c.EffectiveConfig()
+	want := HTTPConfig{
+		HTTPTimeout:            cfg.HTTPTimeout,
+		MaxConnsPerHost:        cfg.MaxConnsPerHost,
+		MaxIdleConnsPerHost:    cfg.MaxIdleConnsPerHost,
+		MaxIdleConns:           cfg.MaxIdleConns,
+		IdleConnTimeout:        cfg.IdleConnTimeout,
+		MaxResponseHeaderBytes: cfg.MaxResponseHeaderBytes,
+		TLSMinVersion:          cfg.TLSMinVersion,
+		TLSMaxVersion:          cfg.TLSMaxVersion,
+		NextProtos:             cfg.NextProtos,
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(HTTPConfig{}, "TLSConfig")); diff != "" {
+		t.Errorf("EffectiveConfig mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := c.ApplyHTTPConfig(nil); err == nil {
+		t.Error("expected an error for a nil config")
+	}
+}
+
+func TestInitializers_TLSVersionBounds(t *testing.T) {
+	cfg := DefaultConfig()
+
+	tests := map[string]struct {
+		init    HTTPClientInitializer
+		wantMin uint16
+		wantMax uint16
+	}{
+		"Default": {
+			init:    DefaultHTTPClientInitializer(),
+			wantMin: tls.VersionTLS12,
+			wantMax: tls.VersionTLS13,
+		},
+		"Configure with DefaultConfig": {
+			init:    ConfigureHTTPClientInitializer(&cfg),
+			wantMin: tls.VersionTLS12,
+			wantMax: tls.VersionTLS13,
+		},
+		"Configure overriding TLSConfig": {
+			init: ConfigureHTTPClientInitializer(&HTTPConfig{
+				TLSConfig:     &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS12},
+				TLSMinVersion: tls.VersionTLS13,
+				TLSMaxVersion: tls.VersionTLS13,
+			}),
+			wantMin: tls.VersionTLS13,
+			wantMax: tls.VersionTLS13,
+		},
+		"Configure keeping TLSConfig": {
+			init: ConfigureHTTPClientInitializer(&HTTPConfig{
+				TLSConfig: &tls.Config{MinVersion: tls.VersionTLS13},
+			}),
+			wantMin: tls.VersionTLS13,
+			wantMax: 0,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(tt.init, "https://example.com", &MockTokenProvider{token: "tok"})
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			tlsCfg := c.BaseTransport().TLSClientConfig
+			if tlsCfg == nil {
+				t.Fatal("expected a TLS config")
+			}
+			if tlsCfg.MinVersion != tt.wantMin || tlsCfg.MaxVersion != tt.wantMax {
+				t.Errorf("TLS versions = %x-%x, want %x-%x", tlsCfg.MinVersion, tlsCfg.MaxVersion, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestInitializers_NextProtos(t *testing.T) {
+	cfg := DefaultConfig()
+
+	tests := map[string]struct {
+		init HTTPClientInitializer
+		want []string
+	}{
+		"Default": {
+			init: DefaultHTTPClientInitializer(),
+			want: []string{"h2", "http/1.1"},
+		},
+		"Configure with DefaultConfig": {
+			init: ConfigureHTTPClientInitializer(&cfg),
+			want: []string{"h2", "http/1.1"},
+		},
+		"Configure with extra protocols": {
+			init: ConfigureHTTPClientInitializer(&HTTPConfig{
+				NextProtos: []string{"h2", "http/1.1", "acme-tls/1"},
+			}),
+			want: []string{"h2", "http/1.1", "acme-tls/1"},
+		},
+		"Configure overriding TLSConfig": {
+			init: ConfigureHTTPClientInitializer(&HTTPConfig{
+				TLSConfig:  &tls.Config{NextProtos: []string{"http/1.1"}},
+				NextProtos: []string{"h2", "http/1.1"},
+			}),
+			want: []string{"h2", "http/1.1"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(tt.init, "https://example.com", &MockTokenProvider{token: "tok"})
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			tlsCfg := c.BaseTransport().TLSClientConfig
+			if tlsCfg == nil {
+				t.Fatal("expected a TLS config")
+			}
+			if diff := cmp.Diff(tt.want, tlsCfg.NextProtos); diff != "" {
+				t.Errorf("NextProtos mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// The configured slice is copied, not shared.
+	protos := []string{"h2", "http/1.1"}
+	c, err := NewClient(ConfigureHTTPClientInitializer(&HTTPConfig{NextProtos: protos}), "https://example.com", &MockTokenProvider{token: "tok"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	protos[0] = "changed"
+	if got := c.BaseTransport().TLSClientConfig.NextProtos[0]; got != "h2" {
+		t.Errorf("NextProtos[0] = %q, want %q", got, "h2")
+	}
+}
@@ -1,15 +1,26 @@
 package appleapi
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/http/httptrace"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 	"unsafe"
@@ -29,6 +40,74 @@ func (m *MockTokenProvider) GetToken(_ time.Time) (string, error) {
 	return m.token, m.err
 }
 
+// recordHandler collects log records, keeping attributes added via WithAttrs.
+type recordHandler struct {
+	mu      *sync.Mutex
+	records *[]slog.Record
+	attrs   []slog.Attr
+}
+
+func newRecordHandler() *recordHandler {
+	return &recordHandler{mu: &sync.Mutex{}, records: &[]slog.Record{}}
+}
+
+func (h *recordHandler) Enabled(_ context.Context, _ slog.Level) bool { return true }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	r = r.Clone()
+	r.AddAttrs(h.attrs...)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.records = append(*h.records, r)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &recordHandler{mu: h.mu, records: h.records, attrs: append(append([]slog.Attr{}, h.attrs...), attrs...)}
+}
+
+func (h *recordHandler) WithGroup(_ string) slog.Handler { return h }
+
+// Records returns a snapshot of the collected records.
+func (h *recordHandler) Records() []slog.Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]slog.Record(nil), *h.records...)
+}
+
+// Messages returns the messages of the collected records.
+func (h *recordHandler) Messages() []string {
+	var msgs []string
+	for _, r := range h.Records() {
+		msgs = append(msgs, r.Message)
+	}
+	return msgs
+}
+
+// findRecord returns the first record with the given message.
+func findRecord(records []slog.Record, msg string) (slog.Record, bool) {
+	for _, r := range records {
+		if r.Message == msg {
+			return r, true
+		}
+	}
+	return slog.Record{}, false
+}
+
+// recordAttr returns the value of the attribute key in r.
+func recordAttr(r slog.Record, key string) (slog.Value, bool) {
+	var val slog.Value
+	var found bool
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == key {
+			val, found = a.Value, true
+			return false
+		}
+		return true
+	})
+	return val, found
+}
+
 // --- Tests ---
 
 func TestHTTPClientInitializers(t *testing.T) {
@@ -36,6 +115,7 @@ func TestHTTPClientInitializers(t *testing.T) {
 		TLSConfig:           &tls.Config{InsecureSkipVerify: true}, // Configure用
 		MaxConnsPerHost:     10,
 		MaxIdleConnsPerHost: 5,
+		MaxIdleConns:        20,
 		IdleConnTimeout:     2 * time.Second,
 		DialTimeout:         1 * time.Second,
 		KeepAlive:           3 * time.Second,
@@ -53,8 +133,8 @@ func TestHTTPClientInitializers(t *testing.T) {
 				"MaxConnsPerHost":     100,
 				"MaxIdleConnsPerHost": 100,
 				"ForceAttemptHTTP2":   true,
-				"Timeout":             time.Duration(0),
-				"TLSClientConfig":     &tls.Config{MaxVersion: tls.VersionTLS13},
+				"Timeout":             30 * time.Second,
+				"TLSClientConfig":     &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS13, NextProtos: []string{"h2", "http/1.1"}},
 			},
 		},
 		"Configure": {
@@ -62,6 +142,7 @@ func TestHTTPClientInitializers(t *testing.T) {
 			wants: map[string]any{
 				"MaxConnsPerHost":     cfg.MaxConnsPerHost,
 				"MaxIdleConnsPerHost": cfg.MaxIdleConnsPerHost,
+				"MaxIdleConns":        cfg.MaxIdleConns,
 				"IdleConnTimeout":     cfg.IdleConnTimeout,
 				"ForceAttemptHTTP2":   true,
 				"Timeout":             cfg.HTTPTimeout,
@@ -104,6 +185,8 @@ func TestHTTPClientInitializers(t *testing.T) {
 					got = tr1.MaxConnsPerHost
 				case "MaxIdleConnsPerHost":
 					got = tr1.MaxIdleConnsPerHost
+				case "MaxIdleConns":
+					got = tr1.MaxIdleConns
 				case "IdleConnTimeout":
 					got = tr1.IdleConnTimeout
 				case "ForceAttemptHTTP2":
@@ -124,6 +207,32 @@ func TestHTTPClientInitializers(t *testing.T) {
 	}
 }
 
+func TestDefaultHTTPClientInitializer_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done() // hang until the client gives up
+	}))
+	defer srv.Close()
+
+	old := defaultClientTimeout
+	defaultClientTimeout = 50 * time.Millisecond
+	defer func() { defaultClientTimeout = old }()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	start := time.Now()
+	_, err = c.Do(req)
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("Do error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Do returned after %v, want about %v", elapsed, defaultClientTimeout)
+	}
+}
+
 func TestNewClient_Options(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 	customTransport := &http.Transport{}
@@ -244,6 +353,62 @@ func TestNewClient_OptionOrder(t *testing.T) {
 	}
 }
 
+func TestNewClient_OptionOrderStable(t *testing.T) {
+	mockTP := &MockTokenProvider{}
+
+	var applied []int
+	withStep := func(n int, order OptionOrder) Option {
+		return Option{
+			order: order,
+			f: func(c *Client) {
+				applied = append(applied, n)
+			},
+		}
+	}
+
+	_, err := NewClient(DefaultHTTPClientInitializer(), "https://example.com", mockTP,
+		withStep(1, Logger),
+		withStep(2, Logger),
+		withStep(0, Development),
+		withStep(3, Logger),
+		withStep(4, Logger),
+		withStep(5, Logger),
+		withStep(6, ClientTrace),
+		withStep(7, Logger),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	want := []int{0, 1, 2, 3, 4, 5, 7, 6}
+	if diff := cmp.Diff(want, applied); diff != "" {
+		t.Errorf("applied order mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewClient_DisableTrace(t *testing.T) {
+	defaultTrace := WithClientTrace(func(l *slog.Logger) *httptrace.ClientTrace {
+		return DefaultClientTrace(l, slog.LevelDebug)
+	})
+
+	tests := map[string][]Option{
+		"disable after trace":  {defaultTrace, WithDisableTrace()},
+		"disable before trace": {WithDisableTrace(), defaultTrace},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(DefaultHTTPClientInitializer(), "https://example.com", &MockTokenProvider{token: "tok"}, opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			if c.Trace != nil || c.traceFunc != nil {
+				t.Errorf("expected no trace, got Trace=%v traceFunc set=%v", c.Trace, c.traceFunc != nil)
+			}
+		})
+	}
+}
+
 func TestCloseIdleConnections(t *testing.T) {
 	c, _ := NewClient(DefaultHTTPClientInitializer(), "https://example.com", &MockTokenProvider{token: "t"})
 	c.CloseIdleConnections() // should not panic
@@ -277,7 +442,7 @@ func TestClient_Do(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, tt.provider)
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, tt.provider, WithAllowPlaintext())
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -303,3 +468,962 @@ func TestClient_Do(t *testing.T) {
 		})
 	}
 }
+
+type loggerKey struct{}
+
+func TestClient_Do_ContextLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	clientH := newRecordHandler()
+	requestH := newRecordHandler()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithLogger(slog.New(clientH)),
+		WithClientTrace(func(l *slog.Logger) *httptrace.ClientTrace {
+			return DefaultClientTrace(l, slog.LevelDebug)
+		}),
+		WithContextLogger(func(ctx context.Context) *slog.Logger {
+			l, _ := ctx.Value(loggerKey{}).(*slog.Logger)
+			return l
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	// Without a logger in the context, records go to the client logger.
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+	if len(clientH.Records()) == 0 {
+		t.Fatal("expected records on the client logger")
+	}
+	if len(requestH.Records()) != 0 {
+		t.Fatalf("unexpected records on the request logger: %v", requestH.Messages())
+	}
+
+	// With a logger in the context, records go to the request logger only.
+	before := len(clientH.Records())
+	ctx := context.WithValue(context.Background(), loggerKey{}, slog.New(requestH))
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err = c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	msgs := requestH.Messages()
+	for _, want := range []string{"GotConn", "Request completed"} {
+		if !slices.Contains(msgs, want) {
+			t.Errorf("request logger missing %q record, got %v", want, msgs)
+		}
+	}
+	// Late trace events of the first request (e.g. PutIdleConn) may still reach
+	// the client logger, so only check the events of the second request.
+	for _, r := range clientH.Records()[before:] {
+		if r.Message == "GotConn" || r.Message == "Request completed" {
+			t.Errorf("client logger received %q for a request with a context logger", r.Message)
+		}
+	}
+}
+
+func TestClient_Do_LogLevel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		provider token.Provider
+		wantMsgs []string
+	}{
+		"routine request suppressed": {
+			provider: &MockTokenProvider{token: "tok"},
+			wantMsgs: nil,
+		},
+		"error still logged": {
+			provider: &MockTokenProvider{err: errors.New("fail")},
+			wantMsgs: []string{"Failed to get token"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newRecordHandler()
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, tt.provider,
+				WithAllowPlaintext(),
+				WithLogger(slog.New(h)),
+				WithLogLevel(slog.LevelWarn),
+				// Trace events are logged at debug level and must be dropped too.
+				WithClientTrace(func(l *slog.Logger) *httptrace.ClientTrace {
+					return DefaultClientTrace(l, slog.LevelDebug)
+				}),
+			)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if diff := cmp.Diff(tt.wantMsgs, h.Messages()); diff != "" {
+				t.Errorf("log messages mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_Do_ProviderFunc(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	var calls int
+	tp := token.ProviderFunc(func(now time.Time) (string, error) {
+		calls++
+		return "static-token", nil
+	})
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, tp, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if diff := cmp.Diff("Bearer static-token", string(body)); diff != "" {
+		t.Errorf("Authorization mismatch (-want +got):\n%s", diff)
+	}
+	if calls != 1 {
+		t.Errorf("provider called %d times, want 1", calls)
+	}
+}
+
+func TestClient_Do_RequestSizeLogging(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer srv.Close()
+
+	h := newRecordHandler()
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithLogger(slog.New(h)),
+		WithRequestSizeLogging(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	body := `{"hello":"world"}`
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	rec, ok := findRecord(h.Records(), "Request completed")
+	if !ok {
+		t.Fatalf("missing request record, got %v", h.Messages())
+	}
+	size, ok := recordAttr(rec, "requestBytes")
+	if !ok {
+		t.Fatal("missing requestBytes attribute")
+	}
+	// The size covers the body plus at least the Authorization and Content-Type headers.
+	minSize := int64(len(body) + len("Authorization: Bearer tok\r\n") + len("Content-Type: application/json\r\n"))
+	if got := size.Int64(); got < minSize {
+		t.Errorf("requestBytes = %d, want >= %d", got, minSize)
+	}
+}
+
+func TestClient_Do_TraceHeaderLogging(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("traceparent", traceparent)
+		w.Header().Set("X-B3-TraceId", "80f198ee56343ba864fe8b2a57d3eff7")
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		opts []Option
+		want map[string]string
+	}{
+		"enabled": {
+			opts: []Option{WithTraceHeaderLogging()},
+			want: map[string]string{"traceparent": traceparent, "b3TraceId": "80f198ee56343ba864fe8b2a57d3eff7"},
+		},
+		"disabled": {
+			want: map[string]string{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newRecordHandler()
+			opts := append([]Option{WithAllowPlaintext(), WithLogger(slog.New(h))}, tt.opts...)
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			resp.Body.Close()
+
+			rec, ok := findRecord(h.Records(), "Request completed")
+			if !ok {
+				t.Fatalf("missing request record, got %v", h.Messages())
+			}
+			got := map[string]string{}
+			for _, key := range []string{"traceparent", "b3", "b3TraceId"} {
+				if v, ok := recordAttr(rec, key); ok {
+					got[key] = v.String()
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("trace attributes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_Do_HostHeader(t *testing.T) {
+	type seen struct{ Host, ServerName string }
+	var got seen
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = seen{r.Host, r.TLS.ServerName}
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		serverName string
+		want       seen
+	}{
+		// No SNI is sent when dialing an IP address.
+		"host header only": {want: seen{Host: "api.example.com"}},
+		"with sni":         {serverName: "example.com", want: seen{Host: "api.example.com", ServerName: "example.com"}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			init := func() (*http.Client, error) {
+				cli := srv.Client()
+				tr := cli.Transport.(*http.Transport).Clone()
+				tr.TLSClientConfig.ServerName = tt.serverName
+				cli.Transport = tr
+				return cli, nil
+			}
+			c, err := NewClient(init, srv.URL, &MockTokenProvider{token: "tok"}, WithHostHeader("api.example.com"))
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("server saw (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_Do_Plaintext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		opts    []Option
+		wantErr error
+	}{
+		"rejected by default": {
+			opts:    nil,
+			wantErr: ErrPlaintextHTTP,
+		},
+		"allowed with override": {
+			opts: []Option{WithAllowPlaintext()},
+		},
+		"allowed in development": {
+			opts: []Option{WithDevelopment()},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var sent bool
+			tp := token.ProviderFunc(func(time.Time) (string, error) {
+				sent = true
+				return "tok", nil
+			})
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, tp, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.Do(req)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Do error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && sent {
+				t.Error("token was requested for a rejected plaintext request")
+			}
+		})
+	}
+}
+
+func TestClient_Do_ConnReuseWarning(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		disableKeepAlives bool
+		wantWarnings      int
+	}{
+		"reused connection": {
+			disableKeepAlives: false,
+			wantWarnings:      0,
+		},
+		"forced new connection": {
+			disableKeepAlives: true,
+			wantWarnings:      2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tr := http.DefaultTransport.(*http.Transport).Clone()
+			tr.DisableKeepAlives = tt.disableKeepAlives
+			defer tr.CloseIdleConnections()
+
+			h := newRecordHandler()
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+				WithAllowPlaintext(),
+				WithLogger(slog.New(h)),
+				WithTransport(tr),
+				WithConnReuseWarning(),
+			)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			for range 3 {
+				req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do failed: %v", err)
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+
+			var warnings int
+			for _, r := range h.Records() {
+				if r.Message == "Connection not reused" && r.Level == slog.LevelWarn {
+					warnings++
+				}
+			}
+			if warnings != tt.wantWarnings {
+				t.Errorf("got %d warnings, want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestClient_Flush(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+
+	tests := map[string]token.Provider{
+		"provider without async work": &MockTokenProvider{token: "tok"},
+		"token provider":              token.NewProvider("KEYID", "TEAMID", priv, token.WithBackgroundRefresh(time.Minute)),
+	}
+
+	for name, tp := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(DefaultHTTPClientInitializer(), "https://example.com", tp)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			if err := c.Flush(context.Background()); err != nil {
+				t.Errorf("Flush failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_Do_LogAttrsFromRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	h := newRecordHandler()
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithLogger(slog.New(h)),
+		WithClientTrace(func(l *slog.Logger) *httptrace.ClientTrace {
+			return DefaultClientTrace(l, slog.LevelDebug)
+		}),
+		WithLogAttrsFromRequest(func(r *http.Request) []slog.Attr {
+			op, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/3/"), "/")
+			return []slog.Attr{slog.String("operation", op)}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/3/device/abc123", strings.NewReader("{}"))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	DrainBody(resp)
+
+	got := map[string]string{}
+	for _, r := range h.Records() {
+		if r.Message != "GotConn" && r.Message != "Request completed" {
+			continue
+		}
+		v, _ := recordAttr(r, "operation")
+		got[r.Message] = v.String()
+	}
+	want := map[string]string{"GotConn": "device", "Request completed": "device"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("operation attribute by record mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_Do_TraceSequence(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	h := newRecordHandler()
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithLogger(slog.New(h)),
+		WithClientTrace(func(l *slog.Logger) *httptrace.ClientTrace {
+			return DefaultClientTrace(l, slog.LevelDebug)
+		}),
+		WithTraceSequence(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for range 2 {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	// Group the GotConn and request records by their sequence id.
+	events := map[uint64][]string{}
+	for _, r := range h.Records() {
+		if r.Message != "GotConn" && r.Message != "Request completed" {
+			continue
+		}
+		v, ok := recordAttr(r, "req")
+		if !ok {
+			t.Fatalf("record %q has no req attribute", r.Message)
+		}
+		events[v.Uint64()] = append(events[v.Uint64()], r.Message)
+	}
+
+	want := map[uint64][]string{
+		1: {"GotConn", "Request completed"},
+		2: {"GotConn", "Request completed"},
+	}
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Errorf("events by request mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_Do_CookieJar(t *testing.T) {
+	var gotCookie string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/"})
+		case "/next":
+			if ck, err := r.Cookie("session"); err == nil {
+				gotCookie = ck.Value
+			}
+		}
+	}))
+	defer srv.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookiejar.New failed: %v", err)
+	}
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithCookieJar(jar),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for _, path := range []string{"/login", "/next"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	if gotCookie != "abc123" {
+		t.Errorf("session cookie = %q, want %q", gotCookie, "abc123")
+	}
+}
+
+func TestNewClient_NoCookieJarByDefault(t *testing.T) {
+	c, err := NewClient(DefaultHTTPClientInitializer(), "https://example.com", &MockTokenProvider{token: "tok"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if c.HTTPClient.Jar != nil {
+		t.Error("expected no cookie jar by default")
+	}
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestClient_Do_TransportWrapper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Wrapped"))
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithTransportWrapper(func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req = req.Clone(req.Context())
+				req.Header.Set("X-Wrapped", "yes")
+				return next.RoundTrip(req)
+			})
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	base := c.BaseTransport()
+	if base == nil {
+		t.Fatal("expected BaseTransport to see through the wrapper")
+	}
+	if base.MaxConnsPerHost != 100 || !base.ForceAttemptHTTP2 {
+		t.Error("expected the initializer's transport settings to be preserved")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "yes" {
+		t.Errorf("wrapper was not invoked, server saw X-Wrapped=%q", body)
+	}
+
+	c.CloseIdleConnections() // reaches the base transport through the wrapper
+}
+
+func TestClient_Do_TraceSampling(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		n    int
+		want int32
+	}{
+		"1 in 5":   {n: 5, want: 2},
+		"disabled": {n: 0, want: 10},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var traced atomic.Int32
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+				WithAllowPlaintext(),
+				WithTraceSampling(tt.n),
+				WithClientTrace(func(*slog.Logger) *httptrace.ClientTrace {
+					return &httptrace.ClientTrace{
+						GetConn: func(string) { traced.Add(1) },
+					}
+				}),
+			)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			for range 10 {
+				req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do failed: %v", err)
+				}
+				resp.Body.Close()
+			}
+			if got := traced.Load(); got != tt.want {
+				t.Errorf("traced requests = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Do_BeforeRequest(t *testing.T) {
+	var gotHeader http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	var order []string
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithBeforeRequest(func(req *http.Request) error {
+			order = append(order, "first")
+			req.Header.Set("X-Auth-Seen", req.Header.Get("Authorization"))
+			return nil
+		}),
+		WithBeforeRequest(func(req *http.Request) error {
+			order = append(order, "second")
+			req.Header.Set("X-Trace-Id", "trace-1")
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if diff := cmp.Diff([]string{"first", "second"}, order); diff != "" {
+		t.Errorf("hook order mismatch (-want +got):\n%s", diff)
+	}
+	if got := gotHeader.Get("X-Auth-Seen"); got != "Bearer tok" {
+		t.Errorf("X-Auth-Seen = %q, want %q", got, "Bearer tok")
+	}
+	if got := gotHeader.Get("X-Trace-Id"); got != "trace-1" {
+		t.Errorf("X-Trace-Id = %q, want %q", got, "trace-1")
+	}
+}
+
+func TestClient_Do_DefaultRequestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	const timeout = 200 * time.Millisecond
+	var gotDeadline time.Time
+	var hasDeadline bool
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithDefaultRequestTimeout(timeout),
+		WithBeforeRequest(func(req *http.Request) error {
+			gotDeadline, hasDeadline = req.Context().Deadline()
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	t.Run("no deadline", func(t *testing.T) {
+		start := time.Now()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(body) != "ok" {
+			t.Errorf("body = %q, %v; want %q", body, err, "ok")
+		}
+		if !hasDeadline {
+			t.Fatal("expected the request to carry a deadline")
+		}
+		if gotDeadline.Before(start.Add(timeout)) || gotDeadline.After(time.Now().Add(timeout)) {
+			t.Errorf("deadline %v after start, want about %v", gotDeadline.Sub(start), timeout)
+		}
+	})
+
+	t.Run("existing deadline", func(t *testing.T) {
+		want := time.Now().Add(time.Hour)
+		ctx, cancel := context.WithDeadline(context.Background(), want)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		resp.Body.Close()
+		if !gotDeadline.Equal(want) {
+			t.Errorf("deadline = %v, want %v", gotDeadline, want)
+		}
+	})
+
+	t.Run("hanging server", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/hang", nil)
+		_, err := c.Do(req)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Do error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestClient_Do_BeforeRequestError(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer srv.Close()
+
+	hookErr := errors.New("hook failed")
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithBeforeRequest(func(*http.Request) error { return hookErr }),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if !errors.Is(err, hookErr) || resp != nil {
+		t.Errorf("Do = %v, %v; want nil, %v", resp, err, hookErr)
+	}
+	if hits != 0 {
+		t.Errorf("server hits = %d, want 0", hits)
+	}
+}
+
+func TestClient_Do_AuthorizationFollowsToken(t *testing.T) {
+	var got []string
+	init := func() (*http.Client, error) {
+		return &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			got = append(got, req.Header.Get("Authorization"))
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})}, nil
+	}
+	tp := &MockTokenProvider{token: "first"}
+	c, err := NewClient(init, "https://example.com", tp)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for _, tok := range []string{"first", "first", "second"} {
+		tp.token = tok
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+		if _, err := c.Do(req); err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+	}
+
+	want := []string{"Bearer first", "Bearer first", "Bearer second"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Authorization mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func BenchmarkClient_Do_CachedToken(b *testing.B) {
+	resp := &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}
+	init := func() (*http.Client, error) {
+		return &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return resp, nil
+		})}, nil
+	}
+	c, err := NewClient(init, "https://example.com", &MockTokenProvider{token: "cached-token"})
+	if err != nil {
+		b.Fatalf("NewClient failed: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/v1/apps", nil)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := c.Do(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestClient_Do_AuthScheme(t *testing.T) {
+	tests := map[string]struct {
+		opts []Option
+		want string
+	}{
+		"default":         {want: "Bearer tok"},
+		"custom":          {opts: []Option{WithAuthScheme("DPoP")}, want: "DPoP tok"},
+		"with space":      {opts: []Option{WithAuthScheme("Bearer extra")}, want: "Bearer tok"},
+		"empty":           {opts: []Option{WithAuthScheme("")}, want: "Bearer tok"},
+		"last one wins":   {opts: []Option{WithAuthScheme("Token"), WithAuthScheme("JWT")}, want: "JWT tok"},
+		"invalid ignored": {opts: []Option{WithAuthScheme("JWT"), WithAuthScheme("a:b")}, want: "JWT tok"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got string
+			init := func() (*http.Client, error) {
+				return &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					got = req.Header.Get("Authorization")
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+				})}, nil
+			}
+			c, err := NewClient(init, "https://example.com", &MockTokenProvider{token: "tok"}, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+			if _, err := c.Do(req); err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_SetDevelopment(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	tp := token.NewProvider("KEYID", "TEAMID", priv,
+		token.WithStringClaim("aud", "production"),
+		token.WithDevelopmentClaims(map[string]any{"aud": "sandbox"}),
+	)
+	c, err := NewClient(DefaultHTTPClientInitializer(), "https://api.example.com", tp,
+		WithDevelopmentHost("https://api.sandbox.example.com"),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	audience := func() string {
+		t.Helper()
+		tok, err := c.TokenProvider.GetToken(time.Now())
+		if err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+		b, err := base64.RawURLEncoding.DecodeString(strings.Split(tok, ".")[1])
+		if err != nil {
+			t.Fatalf("failed to decode payload: %v", err)
+		}
+		var claims map[string]any
+		if err := json.Unmarshal(b, &claims); err != nil {
+			t.Fatalf("failed to unmarshal payload: %v", err)
+		}
+		aud, _ := claims["aud"].(string)
+		return aud
+	}
+
+	steps := []struct {
+		dev      bool
+		wantHost string
+		wantAud  string
+	}{
+		{dev: true, wantHost: "https://api.sandbox.example.com", wantAud: "sandbox"},
+		{dev: false, wantHost: "https://api.example.com", wantAud: "production"},
+	}
+
+	if got := audience(); got != "production" {
+		t.Fatalf("initial aud = %q, want %q", got, "production")
+	}
+	for _, step := range steps {
+		c.SetDevelopment(step.dev)
+		if c.Development != step.dev {
+			t.Errorf("Development = %v, want %v", c.Development, step.dev)
+		}
+		if c.Host != step.wantHost {
+			t.Errorf("Host = %q, want %q", c.Host, step.wantHost)
+		}
+		if got := audience(); got != step.wantAud {
+			t.Errorf("aud = %q, want %q", got, step.wantAud)
+		}
+	}
+}
+
+func TestNewClient_DevelopmentHost(t *testing.T) {
+	tests := map[string]struct {
+		opts     []Option
+		wantHost string
+	}{
+		"production":          {opts: []Option{WithDevelopmentHost("https://dev.example.com")}, wantHost: "https://example.com"},
+		"development":         {opts: []Option{WithDevelopmentHost("https://dev.example.com"), WithDevelopment()}, wantHost: "https://dev.example.com"},
+		"no development host": {opts: []Option{WithDevelopment()}, wantHost: "https://example.com"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(DefaultHTTPClientInitializer(), "https://example.com", &MockTokenProvider{token: "tok"}, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			if c.Host != tt.wantHost {
+				t.Errorf("Host = %q, want %q", c.Host, tt.wantHost)
+			}
+		})
+	}
+}
@@ -2,33 +2,51 @@ package appleapi
 
 import (
 	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
 	"time"
 )
 
 // Default global configuration for all clients.
 var defaultConfig = &HTTPConfig{
-	DialTimeout:         30 * time.Second, // Timeout for establishing TCP connections
-	KeepAlive:           30 * time.Second, // Interval for TCP keep-alive probes
-	IdleConnTimeout:     90 * time.Second, // Max idle time before closing a keep-alive connection
-	MaxConnsPerHost:     30,               // Maximum total connections (idle + active) per host
-	MaxIdleConnsPerHost: 30,               // Maximum idle connections per host
-	ReadIdleTimeout:     15 * time.Second, // Idle period before sending an HTTP/2 PING
-	HTTPTimeout:         60 * time.Second, // Overall HTTP request timeout (connect + transfer + response)
-	TLSConfig: &tls.Config{
-		MinVersion: tls.VersionTLS13, // Require TLS 1.3 for secure connections
-	},
+	DialTimeout:            30 * time.Second,           // Timeout for establishing TCP connections
+	KeepAlive:              30 * time.Second,           // Interval for TCP keep-alive probes
+	IdleConnTimeout:        90 * time.Second,           // Max idle time before closing a keep-alive connection
+	MaxConnsPerHost:        30,                         // Maximum total connections (idle + active) per host
+	MaxIdleConnsPerHost:    30,                         // Maximum idle connections per host
+	MaxIdleConns:           100,                        // Maximum idle connections across all hosts
+	ReadIdleTimeout:        15 * time.Second,           // Idle period before sending an HTTP/2 PING
+	HTTPTimeout:            60 * time.Second,           // Overall HTTP request timeout (connect + transfer + response)
+	TLSMinVersion:          tls.VersionTLS12,           // Apple services require at least TLS 1.2
+	TLSMaxVersion:          tls.VersionTLS13,           // Highest TLS version offered
+	MaxResponseHeaderBytes: 1 << 20,                    // Limit on response header size (1 MiB)
+	NextProtos:             []string{"h2", "http/1.1"}, // ALPN protocols offered, in order of preference
 }
 
 // HTTPConfig defines transport and timeout settings used by clients.
 type HTTPConfig struct {
-	HTTPTimeout         time.Duration // Maximum duration for a complete HTTP request
-	ReadIdleTimeout     time.Duration // Idle period before sending an HTTP/2 PING frame
-	KeepAlive           time.Duration // Interval for TCP keep-alive probes
-	DialTimeout         time.Duration // Timeout for establishing new TCP connections
-	MaxConnsPerHost     int           // Maximum total connections per host (idle + active)
-	IdleConnTimeout     time.Duration // Max time an idle connection is kept alive
-	MaxIdleConnsPerHost int           // Maximum idle connections per host
-	TLSConfig           *tls.Config   // TLS settings for HTTPS connections
+	HTTPTimeout            time.Duration            // Maximum duration for a complete HTTP request
+	ReadIdleTimeout        time.Duration            // Idle period before sending an HTTP/2 PING frame
+	KeepAlive              time.Duration            // Interval for TCP keep-alive probes
+	DialTimeout            time.Duration            // Timeout for establishing new TCP connections
+	MaxConnsPerHost        int                      // Maximum total connections per host (idle + active)
+	IdleConnTimeout        time.Duration            // Max time an idle connection is kept alive
+	MaxIdleConnsPerHost    int                      // Maximum idle connections per host
+	MaxIdleConns           int                      // Maximum idle connections across all hosts; 0 means no limit
+	TLSConfig              *tls.Config              // TLS settings for HTTPS connections
+	TLSMinVersion          uint16                   // Minimum TLS version (e.g. tls.VersionTLS12); 0 keeps TLSConfig's value
+	TLSMaxVersion          uint16                   // Maximum TLS version (e.g. tls.VersionTLS13); 0 keeps TLSConfig's value
+	TLSRenegotiation       tls.RenegotiationSupport // TLS renegotiation policy; 0 (never) keeps TLSConfig's value
+	TLSSessionCacheSize    int                      // Capacity of the TLS session cache for resumption; 0 disables it
+	DisableKeepAlives      bool                     // Use a new connection for every request (for debugging)
+	MaxConnLifetime        time.Duration            // Maximum age of a connection before it is closed, once idle, and replaced; 0 means unlimited
+	MaxResponseHeaderBytes int64                    // Limit on the size of response headers; 0 uses net/http's default
+	LocalAddr              net.Addr                 // Local address (a *net.TCPAddr) outbound connections are bound to; nil lets the system choose
+	HTTP2Fallback          bool                     // Fall back to HTTP/1.1 with a warning if HTTP/2 cannot be configured, instead of failing
+	NextProtos             []string                 // ALPN protocols offered in the TLS handshake; nil keeps TLSConfig's value
 }
 
 // GetDefaultConfigValue returns a copy of the default configuration.
@@ -37,9 +55,316 @@ type HTTPConfig struct {
 func DefaultConfig() HTTPConfig {
 	// Create a shallow copy of the struct
 	configCopy := *defaultConfig
+	configCopy.NextProtos = slices.Clone(defaultConfig.NextProtos)
 	// If TLSConfig is not nil, clone it to ensure a deep copy
 	if defaultConfig.TLSConfig != nil {
 		configCopy.TLSConfig = defaultConfig.TLSConfig.Clone()
 	}
 	return configCopy
 }
+
+// EffectiveConfig reconstructs the HTTPConfig in effect for the client from
+// its live HTTP client and base transport, after the initializer and options
+// have been applied. Settings that cannot be read back from the transport
+// (DialTimeout, KeepAlive, ReadIdleTimeout, TLSSessionCacheSize,
+// MaxConnLifetime, LocalAddr and HTTP2Fallback) are left zero. Only HTTPTimeout is set when the client does not use an *http.Transport.
+func (c *Client) EffectiveConfig() HTTPConfig {
+	cfg := HTTPConfig{HTTPTimeout: c.HTTPClient.Timeout}
+	tr := c.BaseTransport()
+	if tr == nil {
+		return cfg
+	}
+	cfg.MaxConnsPerHost = tr.MaxConnsPerHost
+	cfg.MaxIdleConnsPerHost = tr.MaxIdleConnsPerHost
+	cfg.MaxIdleConns = tr.MaxIdleConns
+	cfg.IdleConnTimeout = tr.IdleConnTimeout
+	cfg.DisableKeepAlives = tr.DisableKeepAlives
+	cfg.MaxResponseHeaderBytes = tr.MaxResponseHeaderBytes
+	if tr.TLSClientConfig != nil {
+		cfg.TLSConfig = tr.TLSClientConfig.Clone()
+		cfg.TLSMinVersion = tr.TLSClientConfig.MinVersion
+		cfg.TLSMaxVersion = tr.TLSClientConfig.MaxVersion
+		cfg.NextProtos = slices.Clone(tr.TLSClientConfig.NextProtos)
+	}
+	return cfg
+}
+
+// httpConfigJSON is the JSON form of HTTPConfig. Durations are strings such
+// as "30s" and TLS versions are strings such as "1.2"; zero values are omitted.
+type httpConfigJSON struct {
+	HTTPTimeout            string   `json:"httpTimeout,omitempty"`
+	ReadIdleTimeout        string   `json:"readIdleTimeout,omitempty"`
+	KeepAlive              string   `json:"keepAlive,omitempty"`
+	DialTimeout            string   `json:"dialTimeout,omitempty"`
+	MaxConnsPerHost        int      `json:"maxConnsPerHost,omitempty"`
+	IdleConnTimeout        string   `json:"idleConnTimeout,omitempty"`
+	MaxIdleConnsPerHost    int      `json:"maxIdleConnsPerHost,omitempty"`
+	MaxIdleConns           int      `json:"maxIdleConns,omitempty"`
+	TLS                    *tlsSpec `json:"tls,omitempty"`
+	TLSMinVersion          string   `json:"tlsMinVersion,omitempty"`
+	TLSMaxVersion          string   `json:"tlsMaxVersion,omitempty"`
+	TLSRenegotiation       string   `json:"tlsRenegotiation,omitempty"`
+	TLSSessionCacheSize    int      `json:"tlsSessionCacheSize,omitempty"`
+	DisableKeepAlives      bool     `json:"disableKeepAlives,omitempty"`
+	MaxConnLifetime        string   `json:"maxConnLifetime,omitempty"`
+	MaxResponseHeaderBytes int64    `json:"maxResponseHeaderBytes,omitempty"`
+	LocalAddr              string   `json:"localAddr,omitempty"`
+	HTTP2Fallback          bool     `json:"http2Fallback,omitempty"`
+	NextProtos             []string `json:"nextProtos,omitempty"`
+}
+
+// tlsSpec is the serializable subset of a tls.Config. Certificates,
+// callbacks and other settings that cannot be represented in JSON are not
+// included.
+type tlsSpec struct {
+	MinVersion   string   `json:"minVersion,omitempty"`
+	MaxVersion   string   `json:"maxVersion,omitempty"`
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+	ServerName   string   `json:"serverName,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface for HTTPConfig.
+// Durations are written as strings such as "30s" and TLS versions as
+// strings such as "1.2". Of TLSConfig, only the version bounds, cipher
+// suite names and server name are written.
+func (cfg HTTPConfig) MarshalJSON() ([]byte, error) {
+	v := httpConfigJSON{
+		HTTPTimeout:            formatDuration(cfg.HTTPTimeout),
+		ReadIdleTimeout:        formatDuration(cfg.ReadIdleTimeout),
+		KeepAlive:              formatDuration(cfg.KeepAlive),
+		DialTimeout:            formatDuration(cfg.DialTimeout),
+		MaxConnsPerHost:        cfg.MaxConnsPerHost,
+		IdleConnTimeout:        formatDuration(cfg.IdleConnTimeout),
+		MaxIdleConnsPerHost:    cfg.MaxIdleConnsPerHost,
+		MaxIdleConns:           cfg.MaxIdleConns,
+		TLSSessionCacheSize:    cfg.TLSSessionCacheSize,
+		DisableKeepAlives:      cfg.DisableKeepAlives,
+		MaxConnLifetime:        formatDuration(cfg.MaxConnLifetime),
+		MaxResponseHeaderBytes: cfg.MaxResponseHeaderBytes,
+		HTTP2Fallback:          cfg.HTTP2Fallback,
+		NextProtos:             cfg.NextProtos,
+	}
+	if cfg.LocalAddr != nil {
+		v.LocalAddr = cfg.LocalAddr.String()
+	}
+	var err error
+	if v.TLSMinVersion, err = formatTLSVersion(cfg.TLSMinVersion); err != nil {
+		return nil, err
+	}
+	if v.TLSMaxVersion, err = formatTLSVersion(cfg.TLSMaxVersion); err != nil {
+		return nil, err
+	}
+	if v.TLSRenegotiation, err = formatRenegotiation(cfg.TLSRenegotiation); err != nil {
+		return nil, err
+	}
+	if tc := cfg.TLSConfig; tc != nil {
+		spec := &tlsSpec{ServerName: tc.ServerName}
+		if spec.MinVersion, err = formatTLSVersion(tc.MinVersion); err != nil {
+			return nil, err
+		}
+		if spec.MaxVersion, err = formatTLSVersion(tc.MaxVersion); err != nil {
+			return nil, err
+		}
+		for _, id := range tc.CipherSuites {
+			spec.CipherSuites = append(spec.CipherSuites, tls.CipherSuiteName(id))
+		}
+		v.TLS = spec
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for HTTPConfig.
+// It accepts the form written by MarshalJSON. Fields that are absent or zero
+// in data are left unchanged, so a partial document can be applied on top of
+// DefaultConfig.
+func (cfg *HTTPConfig) UnmarshalJSON(data []byte) error {
+	var v httpConfigJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	durations := []struct {
+		name string
+		s    string
+		dst  *time.Duration
+	}{
+		{"httpTimeout", v.HTTPTimeout, &cfg.HTTPTimeout},
+		{"readIdleTimeout", v.ReadIdleTimeout, &cfg.ReadIdleTimeout},
+		{"keepAlive", v.KeepAlive, &cfg.KeepAlive},
+		{"dialTimeout", v.DialTimeout, &cfg.DialTimeout},
+		{"idleConnTimeout", v.IdleConnTimeout, &cfg.IdleConnTimeout},
+		{"maxConnLifetime", v.MaxConnLifetime, &cfg.MaxConnLifetime},
+	}
+	for _, d := range durations {
+		if d.s == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.s)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", d.name, err)
+		}
+		*d.dst = parsed
+	}
+
+	versions := []struct {
+		name string
+		s    string
+		dst  *uint16
+	}{
+		{"tlsMinVersion", v.TLSMinVersion, &cfg.TLSMinVersion},
+		{"tlsMaxVersion", v.TLSMaxVersion, &cfg.TLSMaxVersion},
+	}
+	for _, ver := range versions {
+		if ver.s == "" {
+			continue
+		}
+		parsed, err := parseTLSVersion(ver.s)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", ver.name, err)
+		}
+		*ver.dst = parsed
+	}
+	if v.TLSRenegotiation != "" {
+		r, err := parseRenegotiation(v.TLSRenegotiation)
+		if err != nil {
+			return err
+		}
+		cfg.TLSRenegotiation = r
+	}
+
+	if v.TLS != nil {
+		tc := &tls.Config{ServerName: v.TLS.ServerName}
+		var err error
+		if v.TLS.MinVersion != "" {
+			if tc.MinVersion, err = parseTLSVersion(v.TLS.MinVersion); err != nil {
+				return fmt.Errorf("invalid tls.minVersion: %w", err)
+			}
+		}
+		if v.TLS.MaxVersion != "" {
+			if tc.MaxVersion, err = parseTLSVersion(v.TLS.MaxVersion); err != nil {
+				return fmt.Errorf("invalid tls.maxVersion: %w", err)
+			}
+		}
+		for _, name := range v.TLS.CipherSuites {
+			id, ok := cipherSuiteID(name)
+			if !ok {
+				return fmt.Errorf("unknown cipher suite %q", name)
+			}
+			tc.CipherSuites = append(tc.CipherSuites, id)
+		}
+		cfg.TLSConfig = tc
+	}
+
+	if v.MaxConnsPerHost != 0 {
+		cfg.MaxConnsPerHost = v.MaxConnsPerHost
+	}
+	if v.MaxIdleConnsPerHost != 0 {
+		cfg.MaxIdleConnsPerHost = v.MaxIdleConnsPerHost
+	}
+	if v.MaxIdleConns != 0 {
+		cfg.MaxIdleConns = v.MaxIdleConns
+	}
+	if v.TLSSessionCacheSize != 0 {
+		cfg.TLSSessionCacheSize = v.TLSSessionCacheSize
+	}
+	if v.DisableKeepAlives {
+		cfg.DisableKeepAlives = true
+	}
+	if v.HTTP2Fallback {
+		cfg.HTTP2Fallback = true
+	}
+	if v.NextProtos != nil {
+		cfg.NextProtos = v.NextProtos
+	}
+	if v.MaxResponseHeaderBytes != 0 {
+		cfg.MaxResponseHeaderBytes = v.MaxResponseHeaderBytes
+	}
+	if v.LocalAddr != "" {
+		addr, err := net.ResolveTCPAddr("tcp", v.LocalAddr)
+		if err != nil {
+			return fmt.Errorf("invalid localAddr: %w", err)
+		}
+		cfg.LocalAddr = addr
+	}
+	return nil
+}
+
+// formatDuration returns d as a string such as "30s", or "" for zero.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// tlsVersions maps TLS versions to their JSON names.
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "1.0",
+	tls.VersionTLS11: "1.1",
+	tls.VersionTLS12: "1.2",
+	tls.VersionTLS13: "1.3",
+}
+
+// formatTLSVersion returns the JSON name of a TLS version, or "" for zero.
+func formatTLSVersion(v uint16) (string, error) {
+	if v == 0 {
+		return "", nil
+	}
+	name, ok := tlsVersions[v]
+	if !ok {
+		return "", fmt.Errorf("unknown TLS version 0x%04x", v)
+	}
+	return name, nil
+}
+
+// parseTLSVersion parses a TLS version such as "1.2" or "TLS 1.2".
+func parseTLSVersion(s string) (uint16, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(s), "TLS"))
+	for v, name := range tlsVersions {
+		if s == name {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown TLS version %q", s)
+}
+
+// renegotiationNames maps TLS renegotiation policies to their JSON names.
+var renegotiationNames = map[tls.RenegotiationSupport]string{
+	tls.RenegotiateNever:          "never",
+	tls.RenegotiateOnceAsClient:   "once",
+	tls.RenegotiateFreelyAsClient: "freely",
+}
+
+// formatRenegotiation returns the JSON name of r, or "" for the default (never).
+func formatRenegotiation(r tls.RenegotiationSupport) (string, error) {
+	if r == tls.RenegotiateNever {
+		return "", nil
+	}
+	name, ok := renegotiationNames[r]
+	if !ok {
+		return "", fmt.Errorf("unknown TLS renegotiation policy %d", r)
+	}
+	return name, nil
+}
+
+// parseRenegotiation parses a TLS renegotiation policy name.
+func parseRenegotiation(s string) (tls.RenegotiationSupport, error) {
+	for r, name := range renegotiationNames {
+		if s == name {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid tlsRenegotiation %q: expected never, once or freely", s)
+}
+
+// cipherSuiteID returns the ID of the cipher suite with the given name.
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, list := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, cs := range list {
+			if cs.Name == name {
+				return cs.ID, true
+			}
+		}
+	}
+	return 0, false
+}
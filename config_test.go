@@ -0,0 +1,112 @@
+package appleapi
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestHTTPConfig_JSONRoundTrip(t *testing.T) {
+	tests := map[string]HTTPConfig{
+		"default": DefaultConfig(),
+		"tuned": {
+			HTTPTimeout:            90 * time.Second,
+			ReadIdleTimeout:        1500 * time.Millisecond,
+			DialTimeout:            5 * time.Second,
+			MaxConnsPerHost:        4,
+			IdleConnTimeout:        2 * time.Minute,
+			TLSMinVersion:          tls.VersionTLS13,
+			TLSRenegotiation:       tls.RenegotiateOnceAsClient,
+			TLSSessionCacheSize:    64,
+			DisableKeepAlives:      true,
+			HTTP2Fallback:          true,
+			NextProtos:             []string{"http/1.1"},
+			MaxConnLifetime:        time.Hour + 30*time.Minute,
+			MaxResponseHeaderBytes: 4096,
+			LocalAddr:              &net.TCPAddr{IP: net.ParseIP("192.0.2.10").To4(), Port: 0},
+			TLSConfig: &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				MaxVersion:   tls.VersionTLS13,
+				CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+				ServerName:   "api.push.apple.com",
+			},
+		},
+		"zero": {},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var got HTTPConfig
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal(%s) failed: %v", data, err)
+			}
+			if diff := cmp.Diff(cfg, got, cmpopts.IgnoreUnexported(tls.Config{})); diff != "" {
+				t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPConfig_MarshalJSON_Format(t *testing.T) {
+	cfg := HTTPConfig{
+		HTTPTimeout:   30 * time.Second,
+		TLSMinVersion: tls.VersionTLS12,
+		TLSConfig:     &tls.Config{CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256}},
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `{"httpTimeout":"30s","tls":{"cipherSuites":["TLS_AES_128_GCM_SHA256"]},"tlsMinVersion":"1.2"}`
+	if string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+}
+
+func TestHTTPConfig_UnmarshalJSON_Partial(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := json.Unmarshal([]byte(`{"httpTimeout":"10s","tlsMinVersion":"TLS 1.3"}`), &cfg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := DefaultConfig()
+	want.HTTPTimeout = 10 * time.Second
+	want.TLSMinVersion = tls.VersionTLS13
+	if diff := cmp.Diff(want, cfg, cmpopts.IgnoreUnexported(tls.Config{})); diff != "" {
+		t.Errorf("config mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHTTPConfig_UnmarshalJSON_Errors(t *testing.T) {
+	tests := map[string]struct {
+		data    string
+		wantErr string
+	}{
+		"bad duration":      {data: `{"dialTimeout":"soon"}`, wantErr: "invalid dialTimeout"},
+		"numeric duration":  {data: `{"dialTimeout":30}`, wantErr: "cannot unmarshal"},
+		"bad version":       {data: `{"tlsMaxVersion":"2.0"}`, wantErr: "invalid tlsMaxVersion"},
+		"bad nested":        {data: `{"tls":{"minVersion":"ssl3"}}`, wantErr: "invalid tls.minVersion"},
+		"unknown cipher":    {data: `{"tls":{"cipherSuites":["TLS_NOPE"]}}`, wantErr: `unknown cipher suite "TLS_NOPE"`},
+		"bad renegotiation": {data: `{"tlsRenegotiation":"always"}`, wantErr: "invalid tlsRenegotiation"},
+		"bad local addr":    {data: `{"localAddr":"not an address"}`, wantErr: "invalid localAddr"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var cfg HTTPConfig
+			err := json.Unmarshal([]byte(tt.data), &cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Unmarshal error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
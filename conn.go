@@ -0,0 +1,233 @@
+package appleapi
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// dialFunc is the signature of http.Transport.DialContext.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// lifetimeConn is a connection that expires once it has been open for its
+// maximum lifetime. An expired connection is closed as soon as no request
+// tracked by lifetimeTransport is using it, so the transport replaces it with
+// a fresh one without cutting off requests in flight.
+type lifetimeConn struct {
+	net.Conn
+	timer *time.Timer
+
+	mu      sync.Mutex
+	active  int  // Requests in flight on the connection; idle when zero
+	expired bool // Set once the lifetime has elapsed
+}
+
+// Close stops the lifetime timer and closes the underlying connection.
+func (c *lifetimeConn) Close() error {
+	c.timer.Stop()
+	return c.Conn.Close()
+}
+
+// expire marks the connection as expired and closes it if it is idle.
+func (c *lifetimeConn) expire() {
+	c.mu.Lock()
+	c.expired = true
+	idle := c.active == 0
+	c.mu.Unlock()
+	if idle {
+		c.Conn.Close()
+	}
+}
+
+// acquire records a request starting on the connection.
+func (c *lifetimeConn) acquire() {
+	c.mu.Lock()
+	c.active++
+	c.mu.Unlock()
+}
+
+// release records a request finishing on the connection and closes the
+// connection if it has expired and no other request is using it.
+func (c *lifetimeConn) release() {
+	c.mu.Lock()
+	c.active--
+	closeNow := c.active == 0 && c.expired
+	c.mu.Unlock()
+	if closeNow {
+		c.Conn.Close()
+	}
+}
+
+// asLifetimeConn returns the lifetimeConn underlying conn, which may be
+// wrapped in a *tls.Conn, or nil if there is none.
+func asLifetimeConn(conn net.Conn) *lifetimeConn {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *lifetimeConn:
+			return c
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// dialWithLifetime wraps dial so that every connection it returns expires
+// after lifetime has elapsed since it was established. An expired connection
+// is closed right away unless a request sent through a lifetimeTransport is
+// using it, in which case it is closed once the last such request finishes.
+func dialWithLifetime(dial dialFunc, lifetime time.Duration) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		lc := &lifetimeConn{Conn: conn}
+		lc.timer = time.AfterFunc(lifetime, lc.expire)
+		return lc, nil
+	}
+}
+
+// lifetimeTransport tracks which requests are using each connection dialed
+// with dialWithLifetime, so expired connections are closed only when idle.
+type lifetimeTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper. The connection counts as in use
+// until the response body is read to the end or closed. If the transport
+// retries the request on another connection, the previous one is released.
+func (t *lifetimeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		mu   sync.Mutex
+		lc   *lifetimeConn
+		done bool // RoundTrip has returned; later GotConn calls are ignored
+	)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			c := asLifetimeConn(info.Conn)
+			mu.Lock()
+			defer mu.Unlock()
+			if done {
+				return
+			}
+			if c != nil {
+				c.acquire()
+			}
+			if lc != nil {
+				lc.release()
+			}
+			lc = c
+		},
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+
+	mu.Lock()
+	c := lc
+	done = true
+	mu.Unlock()
+	if c == nil {
+		return resp, err
+	}
+	if err != nil {
+		c.release()
+		return resp, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: sync.OnceFunc(c.release)}
+	return resp, nil
+}
+
+// withLifetime wraps tr in a lifetimeTransport when lifetime is positive.
+func withLifetime(tr *http.Transport, lifetime time.Duration) http.RoundTripper {
+	if lifetime <= 0 {
+		return tr
+	}
+	return &lifetimeTransport{base: tr}
+}
+
+// Unwrap returns the wrapped transport.
+func (t *lifetimeTransport) Unwrap() http.RoundTripper { return t.base }
+
+// CloseIdleConnections closes idle connections of the wrapped transport.
+func (t *lifetimeTransport) CloseIdleConnections() {
+	if ci, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// usesLifetime reports whether rt is, or wraps, a lifetimeTransport.
+func usesLifetime(rt http.RoundTripper) bool {
+	for {
+		switch t := rt.(type) {
+		case *lifetimeTransport:
+			return true
+		case interface{ Unwrap() http.RoundTripper }:
+			rt = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// httpClient returns the HTTP client to send a request with ctx. Requests
+// marked with ContextForceNewConn get a copy of the client that uses the
+// transport from freshTransport, so the request is sent on a new connection
+// that is closed afterwards.
+func (c *Client) httpClient(ctx context.Context) *http.Client {
+	if !forceNewConn(ctx) {
+		return c.HTTPClient
+	}
+	rt := c.freshTransport()
+	if rt == nil {
+		return c.HTTPClient
+	}
+	hc := *c.HTTPClient
+	hc.Transport = rt
+	return &hc
+}
+
+// freshTransport returns a copy of the client's transport with its own empty
+// pool and keep-alives disabled, wrapped like the client's transport: in a
+// lifetimeTransport if the original is, then in the WithTransportWrapper
+// wrappers. It is built once and rebuilt only when the base transport
+// changes, such as after ApplyHTTPConfig. It returns nil if the client does
+// not use an *http.Transport.
+func (c *Client) freshTransport() http.RoundTripper {
+	tr := c.BaseTransport()
+	if tr == nil {
+		return nil
+	}
+	c.freshMu.Lock()
+	defer c.freshMu.Unlock()
+	if c.fresh != nil && c.freshBase == tr {
+		return c.fresh
+	}
+	if ci, ok := c.fresh.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+
+	clone := tr.Clone()
+	clone.DisableKeepAlives = true
+	if _, ok := clone.TLSNextProto["h2"]; ok {
+		// The cloned HTTP/2 upgrade still pools connections in the original
+		// transport; let the clone set up its own HTTP/2 support instead.
+		clone.TLSNextProto = nil
+		clone.ForceAttemptHTTP2 = true
+	}
+	var rt http.RoundTripper = clone
+	if usesLifetime(c.HTTPClient.Transport) {
+		rt = &lifetimeTransport{base: rt}
+	}
+	for _, wrap := range c.wrappers {
+		if w := wrap(rt); w != nil {
+			rt = &wrappedTransport{RoundTripper: w, base: rt}
+		}
+	}
+	c.fresh, c.freshBase = rt, tr
+	return rt
+}
@@ -0,0 +1,94 @@
+package appleapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+type (
+	tokenTimeKey     struct{}
+	forceNewConnKey  struct{}
+	streamingBodyKey struct{}
+	traceKey         struct{}
+)
+
+// ContextWithTokenTime returns a copy of ctx that makes Client.Do obtain the
+// token for time t instead of the current time, for example to pin the
+// token's iat claim when replaying or backfilling requests. A provider with a
+// GenerateFor method, such as *token.TokenProvider, signs a fresh token for t
+// on every such request, bypassing its cache, so a pinned token is never
+// returned to other requests. Other providers get t passed to GetToken.
+func ContextWithTokenTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, tokenTimeKey{}, t)
+}
+
+// getToken obtains the token for a request with context ctx from p,
+// honoring a time set with ContextWithTokenTime.
+func getToken(ctx context.Context, p token.Provider) (string, error) {
+	if g, ok := p.(interface {
+		GenerateFor(time.Time) (string, time.Time, error)
+	}); ok {
+		if t, pinned := ctx.Value(tokenTimeKey{}).(time.Time); pinned {
+			tok, _, err := g.GenerateFor(t)
+			return tok, err
+		}
+	}
+	return p.GetToken(tokenTime(ctx))
+}
+
+// tokenTime returns the token time carried by ctx, or the current time if there is none.
+func tokenTime(ctx context.Context) time.Time {
+	if t, ok := ctx.Value(tokenTimeKey{}).(time.Time); ok {
+		return t
+	}
+	return time.Now()
+}
+
+// ContextForceNewConn returns a copy of ctx that makes Client.Do send the
+// request on a newly dialed connection that is closed after the response,
+// instead of one from the connection pool. It is meant for debugging
+// connection-specific problems, not for production traffic: every such
+// request pays for a fresh TCP and TLS handshake. It has no effect when the
+// client does not use an *http.Transport.
+func ContextForceNewConn(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceNewConnKey{}, true)
+}
+
+// forceNewConn reports whether ctx was marked with ContextForceNewConn.
+func forceNewConn(ctx context.Context) bool {
+	v, _ := ctx.Value(forceNewConnKey{}).(bool)
+	return v
+}
+
+// ContextStreamingBody returns a copy of ctx that makes Client.Do send the
+// request body as it is read, without buffering it in memory first. Use it
+// for large uploads. Such a request is never retried (see WithRetry) and is
+// not compressed by WithRequestCompression. Without a GetBody it also cannot
+// move to a failover host. A body of unknown length is sent chunked over
+// HTTP/1.1.
+func ContextStreamingBody(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamingBodyKey{}, true)
+}
+
+// streamingBody reports whether ctx was marked with ContextStreamingBody.
+func streamingBody(ctx context.Context) bool {
+	v, _ := ctx.Value(streamingBodyKey{}).(bool)
+	return v
+}
+
+// ContextWithTrace returns a copy of ctx that turns the client trace hooks on
+// or off for requests sent with it, overriding WithTraceSampling and
+// WithDisableTrace, for example to trace every request of a single tenant
+// while debugging. When enabled and the client has no trace hooks,
+// DefaultClientTrace at slog.LevelDebug is used.
+func ContextWithTrace(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, traceKey{}, enabled)
+}
+
+// contextTrace returns the trace decision carried by ctx and whether there is one.
+func contextTrace(ctx context.Context) (enabled, ok bool) {
+	enabled, ok = ctx.Value(traceKey{}).(bool)
+	return enabled, ok
+}
@@ -0,0 +1,301 @@
+package appleapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+func TestClient_Do_ContextWithTokenTime(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	now := time.Now()
+
+	tests := map[string]struct {
+		pinned    time.Time
+		warmCache bool
+	}{
+		"cold cache":        {pinned: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		"warm cache":        {pinned: now.Add(-2 * time.Hour), warmCache: true},
+		"future cold cache": {pinned: now.Add(10 * time.Minute)},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, token.NewProvider("KEYID", "TEAMID", priv), WithAllowPlaintext())
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			// issuedAt sends a request with ctx and returns the iat of its token.
+			issuedAt := func(ctx context.Context) int64 {
+				req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do failed: %v", err)
+				}
+				resp.Body.Close()
+
+				parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+				if len(parts) != 3 {
+					t.Fatalf("unexpected token %q", auth)
+				}
+				b, err := base64.RawURLEncoding.DecodeString(parts[1])
+				if err != nil {
+					t.Fatalf("failed to decode payload: %v", err)
+				}
+				var payload token.Payload
+				if err := json.Unmarshal(b, &payload); err != nil {
+					t.Fatalf("failed to unmarshal payload: %v", err)
+				}
+				return payload.IssuedAt
+			}
+
+			var cached int64
+			if tt.warmCache {
+				cached = issuedAt(context.Background())
+			}
+			if got := issuedAt(ContextWithTokenTime(context.Background(), tt.pinned)); got != tt.pinned.Unix() {
+				t.Errorf("pinned iat = %d, want %d", got, tt.pinned.Unix())
+			}
+
+			// The pinned token must not leak into the shared cache.
+			got := issuedAt(context.Background())
+			if got < now.Unix() || got > time.Now().Unix() {
+				t.Errorf("iat of a later unpinned request = %d, want the current time %d", got, now.Unix())
+			}
+			if tt.warmCache && got != cached {
+				t.Errorf("iat of a later unpinned request = %d, want the cached %d", got, cached)
+			}
+		})
+	}
+}
+
+func TestTokenTime_Default(t *testing.T) {
+	before := time.Now()
+	got := tokenTime(context.Background())
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("tokenTime = %v, want the current time", got)
+	}
+}
+
+func TestClient_Do_ContextForceNewConn(t *testing.T) {
+	tests := map[string]func(http.Handler) *httptest.Server{
+		"http1": httptest.NewServer,
+		"http2": func(h http.Handler) *httptest.Server {
+			srv := httptest.NewUnstartedServer(h)
+			srv.EnableHTTP2 = true
+			srv.StartTLS()
+			return srv
+		},
+	}
+
+	for name, newServer := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := newServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer srv.Close()
+
+			init := func() (*http.Client, error) { return srv.Client(), nil }
+			c, err := NewClient(init, srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			// do sends a request and reports whether it reused a pooled connection.
+			do := func(ctx context.Context) bool {
+				var reused bool
+				ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+					GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+				})
+				req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do failed: %v", err)
+				}
+				DrainBody(resp)
+				return reused
+			}
+
+			do(context.Background())
+			if !do(context.Background()) {
+				t.Fatal("expected the second request to reuse the pooled connection")
+			}
+			if do(ContextForceNewConn(context.Background())) {
+				t.Error("expected the forced request to use a new connection")
+			}
+			if !do(context.Background()) {
+				t.Error("expected later requests to keep using the pooled connection")
+			}
+		})
+	}
+}
+
+func TestClient_httpClient_ForceNewConn(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxConnLifetime = time.Minute
+	var wrapped int
+	c, err := NewClient(ConfigureHTTPClientInitializer(&cfg), "https://example.com", &MockTokenProvider{token: "tok"},
+		WithTransportWrapper(func(rt http.RoundTripper) http.RoundTripper {
+			wrapped++
+			return roundTripperFunc(rt.RoundTrip)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	ctx := ContextForceNewConn(context.Background())
+
+	first := c.httpClient(ctx).Transport
+	if _, ok := first.(*wrappedTransport); !ok {
+		t.Errorf("transport = %T, want the client's wrappers applied", first)
+	}
+	if !usesLifetime(first) {
+		t.Error("expected the transport to keep the MaxConnLifetime wrapper")
+	}
+	if second := c.httpClient(ctx).Transport; second != first {
+		t.Error("expected the transport to be reused across requests")
+	}
+
+	if err := c.ApplyHTTPConfig(&cfg); err != nil {
+		t.Fatalf("ApplyHTTPConfig failed: %v", err)
+	}
+	if third := c.httpClient(ctx).Transport; third == first {
+		t.Error("expected a new transport after ApplyHTTPConfig")
+	}
+	// Once for the client at creation and after ApplyHTTPConfig, once for
+	// each derived transport.
+	if wrapped != 4 {
+		t.Errorf("wrapper applied %d times, want 4", wrapped)
+	}
+}
+
+func TestClient_Do_ContextStreamingBody(t *testing.T) {
+	var calls atomic.Int32
+	var gotBody string
+	var gotEncoding []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotEncoding = r.TransferEncoding
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithRetry(3, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		for i := range 3 {
+			fmt.Fprintf(pw, "chunk%d;", i)
+		}
+		pw.Close()
+	}()
+	req, _ := http.NewRequestWithContext(ContextStreamingBody(context.Background()), http.MethodPost, srv.URL, pr)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	DrainBody(resp)
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server called %d times, want 1 (no retry)", got)
+	}
+	if gotBody != "chunk0;chunk1;chunk2;" {
+		t.Errorf("body = %q, want %q", gotBody, "chunk0;chunk1;chunk2;")
+	}
+	// A buffered body would have been sent with a Content-Length.
+	if diff := cmp.Diff([]string{"chunked"}, gotEncoding); diff != "" {
+		t.Errorf("Transfer-Encoding mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_Do_ContextWithTrace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	clientTrace := WithClientTrace(func(l *slog.Logger) *httptrace.ClientTrace {
+		return DefaultClientTrace(l, slog.LevelDebug)
+	})
+	enabled := func(ctx context.Context) context.Context { return ContextWithTrace(ctx, true) }
+	disabled := func(ctx context.Context) context.Context { return ContextWithTrace(ctx, false) }
+
+	tests := map[string]struct {
+		opts       []Option
+		ctx        func(context.Context) context.Context
+		wantTraced bool
+	}{
+		"no client trace":                  {wantTraced: false},
+		"enabled without client trace":     {ctx: enabled, wantTraced: true},
+		"sampled out":                      {opts: []Option{clientTrace, WithTraceSampling(1000)}, wantTraced: false},
+		"enabled despite sampling":         {opts: []Option{clientTrace, WithTraceSampling(1000)}, ctx: enabled, wantTraced: true},
+		"enabled despite WithDisableTrace": {opts: []Option{clientTrace, WithDisableTrace()}, ctx: enabled, wantTraced: true},
+		"disabled with client trace":       {opts: []Option{clientTrace}, ctx: disabled, wantTraced: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newRecordHandler()
+			opts := append([]Option{WithAllowPlaintext(), WithLogger(slog.New(h))}, tt.opts...)
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			do := func(ctx context.Context) {
+				req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do failed: %v", err)
+				}
+				DrainBody(resp)
+			}
+			// The first request takes the traced slot of WithTraceSampling.
+			do(context.Background())
+			before := len(h.Records())
+
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx(ctx)
+			}
+			do(ctx)
+			traced := slices.ContainsFunc(h.Records()[before:], func(r slog.Record) bool { return r.Message == "GotConn" })
+			if traced != tt.wantTraced {
+				t.Errorf("request traced = %v, want %v", traced, tt.wantTraced)
+			}
+		})
+	}
+}
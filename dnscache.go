@@ -0,0 +1,88 @@
+package appleapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// hostResolver resolves host names; *net.Resolver implements it.
+type hostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// dnsCache memoizes host name resolutions for a fixed TTL. Names that do
+// not exist are cached for the same TTL; other lookup failures are not
+// cached, so a transient resolver problem is retried on the next dial.
+type dnsCache struct {
+	resolver hostResolver
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+// dnsEntry is a cached resolution and the time it expires.
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// newDNSCache returns a cache in front of resolver whose entries live for ttl.
+func newDNSCache(resolver hostResolver, ttl time.Duration) *dnsCache {
+	return &dnsCache{resolver: resolver, ttl: ttl, now: time.Now, entries: make(map[string]dnsEntry)}
+}
+
+// lookup returns the addresses of host, from the cache if a fresh entry exists.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	now := c.now()
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.addrs, e.err
+	}
+
+	addrs, err := c.resolver.LookupIPAddr(ctx, host)
+	var dnsErr *net.DNSError
+	if err == nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		c.mu.Lock()
+		c.entries[host] = dnsEntry{addrs: addrs, err: err, expires: now.Add(c.ttl)}
+		c.mu.Unlock()
+	}
+	return addrs, err
+}
+
+// dialWithDNSCache wraps dial so that host names are resolved through cache
+// and the resolved addresses are dialed in order until one succeeds.
+// Addresses that are already IP literals are dialed unchanged.
+func dialWithDNSCache(dial dialFunc, cache *dnsCache) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		addrs, err := cache.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+		}
+		for _, ip := range addrs {
+			var conn net.Conn
+			conn, err = dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,139 @@
+package appleapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// countingResolver resolves names from a fixed table and counts lookups.
+type countingResolver struct {
+	hosts   map[string][]net.IPAddr
+	err     error
+	lookups int
+}
+
+func (r *countingResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	r.lookups++
+	if r.err != nil {
+		return nil, r.err
+	}
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func TestDNSCache_Lookup(t *testing.T) {
+	loopback := []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}
+	tests := map[string]struct {
+		resolver    *countingResolver
+		host        string
+		advance     time.Duration
+		wantLookups int
+		wantErr     bool
+	}{
+		"hit within ttl": {
+			resolver:    &countingResolver{hosts: map[string][]net.IPAddr{"api.example.com": loopback}},
+			host:        "api.example.com",
+			advance:     30 * time.Second,
+			wantLookups: 1,
+		},
+		"miss after ttl": {
+			resolver:    &countingResolver{hosts: map[string][]net.IPAddr{"api.example.com": loopback}},
+			host:        "api.example.com",
+			advance:     time.Minute,
+			wantLookups: 2,
+		},
+		"not found is cached": {
+			resolver:    &countingResolver{},
+			host:        "missing.example.com",
+			advance:     30 * time.Second,
+			wantLookups: 1,
+			wantErr:     true,
+		},
+		"temporary failure is not cached": {
+			resolver:    &countingResolver{err: &net.DNSError{Err: "server misbehaving", Name: "api.example.com", IsTemporary: true}},
+			host:        "api.example.com",
+			advance:     time.Second,
+			wantLookups: 2,
+			wantErr:     true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+			cache := newDNSCache(tt.resolver, time.Minute)
+			cache.now = func() time.Time { return now }
+
+			for range 2 {
+				_, err := cache.lookup(context.Background(), tt.host)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("lookup error = %v, wantErr %v", err, tt.wantErr)
+				}
+				now = now.Add(tt.advance)
+			}
+			if tt.resolver.lookups != tt.wantLookups {
+				t.Errorf("resolver called %d times, want %d", tt.resolver.lookups, tt.wantLookups)
+			}
+		})
+	}
+}
+
+func TestDialWithDNSCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Host)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	_, port, _ := net.SplitHostPort(u.Host)
+
+	resolver := &countingResolver{hosts: map[string][]net.IPAddr{
+		// Nothing listens on the first address, so the second one is dialed.
+		"api.example.test": {{IP: net.IPv4(127, 0, 0, 2)}, {IP: net.IPv4(127, 0, 0, 1)}},
+	}}
+	cache := newDNSCache(resolver, time.Minute)
+	var dialed []string
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	tr := &http.Transport{DialContext: dialWithDNSCache(dial, cache), DisableKeepAlives: true}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	for range 2 {
+		resp, err := hc.Get("http://api.example.test:" + port)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want := "api.example.test:" + port; string(body) != want {
+			t.Errorf("Host seen by server = %q, want %q", body, want)
+		}
+	}
+
+	if resolver.lookups != 1 {
+		t.Errorf("resolver called %d times, want 1", resolver.lookups)
+	}
+	bad, good := net.JoinHostPort("127.0.0.2", port), net.JoinHostPort("127.0.0.1", port)
+	if diff := cmp.Diff([]string{bad, good, bad, good}, dialed); diff != "" {
+		t.Errorf("dialed addresses mismatch (-want +got):\n%s", diff)
+	}
+
+	var dnsErr *net.DNSError
+	if _, err := hc.Get("http://missing.example.test:" + port); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("Get error = %v, want a not-found DNS error", err)
+	}
+}
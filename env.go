@@ -0,0 +1,72 @@
+package appleapi
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+// Environment variables read by FromEnv.
+const (
+	EnvKeyPath     = "APPLE_KEY_PATH"     // Path to the .p8 private key file (required)
+	EnvKeyID       = "APPLE_KEY_ID"       // Key ID of the private key (required)
+	EnvTeamID      = "APPLE_TEAM_ID"      // Team ID used as the token issuer (required)
+	EnvHost        = "APPLE_HOST"         // Base URL of the Apple API (required)
+	EnvDevelopment = "APPLE_DEVELOPMENT"  // Enables development mode, e.g. "true" (optional)
+	EnvHTTPTimeout = "APPLE_HTTP_TIMEOUT" // Overall request timeout, e.g. "30s" (optional)
+	EnvTokenTTL    = "APPLE_TOKEN_TTL"    // Token time-to-live, e.g. "20m" (optional)
+)
+
+// FromEnv builds a Client from environment variables, for applications
+// configured through their environment. It loads the key at APPLE_KEY_PATH,
+// creates a token provider for APPLE_KEY_ID and APPLE_TEAM_ID, and connects
+// to APPLE_HOST with ConfigureHTTPClientInitializer and DefaultConfig. The
+// optional APPLE_DEVELOPMENT, APPLE_HTTP_TIMEOUT and APPLE_TOKEN_TTL adjust
+// the defaults. opts are applied after the settings from the environment.
+func FromEnv(opts ...Option) (*Client, error) {
+	var vals [4]string
+	for i, name := range []string{EnvKeyPath, EnvKeyID, EnvTeamID, EnvHost} {
+		vals[i] = os.Getenv(name)
+		if vals[i] == "" {
+			return nil, fmt.Errorf("missing environment variable %s", name)
+		}
+	}
+	keyPath, keyID, teamID, host := vals[0], vals[1], vals[2], vals[3]
+
+	cfg := DefaultConfig()
+	if v := os.Getenv(EnvHTTPTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid %s %q: expected a duration such as 30s", EnvHTTPTimeout, v)
+		}
+		cfg.HTTPTimeout = d
+	}
+	var tokenOpts []token.Option
+	if v := os.Getenv(EnvTokenTTL); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s %q: expected a positive duration such as 20m", EnvTokenTTL, v)
+		}
+		tokenOpts = append(tokenOpts, token.WithTTL(d))
+	}
+	var envOpts []Option
+	if v := os.Getenv(EnvDevelopment); v != "" {
+		dev, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: expected a boolean", EnvDevelopment, v)
+		}
+		if dev {
+			envOpts = append(envOpts, WithDevelopment())
+		}
+	}
+
+	key, err := token.LoadPKCS8File(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	tp := token.NewProvider(keyID, teamID, key, tokenOpts...)
+	return NewClient(ConfigureHTTPClientInitializer(&cfg), host, tp, append(envOpts, opts...)...)
+}
@@ -0,0 +1,121 @@
+package appleapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestKey writes a PKCS#8 PEM-encoded ECDSA key into dir and returns its path.
+func writeTestKey(t *testing.T, dir string) string {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to marshal PKCS8 private key: %v", err)
+	}
+	path := filepath.Join(dir, "AuthKey.p8")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	return path
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(EnvKeyPath, writeTestKey(t, t.TempDir()))
+	t.Setenv(EnvKeyID, "KEYID12345")
+	t.Setenv(EnvTeamID, "TEAMID1234")
+	t.Setenv(EnvHost, "https://api.example.com")
+	t.Setenv(EnvHTTPTimeout, "15s")
+	t.Setenv(EnvDevelopment, "true")
+	t.Setenv(EnvTokenTTL, "20m")
+
+	c, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv() error: %v", err)
+	}
+	if c.Host != "https://api.example.com" {
+		t.Errorf("Host = %q, want %q", c.Host, "https://api.example.com")
+	}
+	if !c.Development {
+		t.Error("expected Development to be enabled")
+	}
+	if c.HTTPClient.Timeout != 15*time.Second {
+		t.Errorf("Timeout = %v, want %v", c.HTTPClient.Timeout, 15*time.Second)
+	}
+	if _, err := c.TokenProvider.GetToken(time.Now()); err != nil {
+		t.Errorf("GetToken() error: %v", err)
+	}
+}
+
+func TestFromEnv_Errors(t *testing.T) {
+	keyPath := writeTestKey(t, t.TempDir())
+
+	testCases := map[string]struct {
+		env     map[string]string
+		wantErr string
+	}{
+		"missing key path": {
+			env:     map[string]string{EnvKeyPath: ""},
+			wantErr: "missing environment variable APPLE_KEY_PATH",
+		},
+		"missing key id": {
+			env:     map[string]string{EnvKeyID: ""},
+			wantErr: "missing environment variable APPLE_KEY_ID",
+		},
+		"missing team id": {
+			env:     map[string]string{EnvTeamID: ""},
+			wantErr: "missing environment variable APPLE_TEAM_ID",
+		},
+		"missing host": {
+			env:     map[string]string{EnvHost: ""},
+			wantErr: "missing environment variable APPLE_HOST",
+		},
+		"invalid timeout": {
+			env:     map[string]string{EnvHTTPTimeout: "soon"},
+			wantErr: `invalid APPLE_HTTP_TIMEOUT "soon"`,
+		},
+		"invalid ttl": {
+			env:     map[string]string{EnvTokenTTL: "-1m"},
+			wantErr: `invalid APPLE_TOKEN_TTL "-1m"`,
+		},
+		"invalid development": {
+			env:     map[string]string{EnvDevelopment: "maybe"},
+			wantErr: `invalid APPLE_DEVELOPMENT "maybe"`,
+		},
+		"unreadable key": {
+			env:     map[string]string{EnvKeyPath: filepath.Join(t.TempDir(), "missing.p8")},
+			wantErr: "missing.p8",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(EnvKeyPath, keyPath)
+			t.Setenv(EnvKeyID, "KEYID12345")
+			t.Setenv(EnvTeamID, "TEAMID1234")
+			t.Setenv(EnvHost, "https://api.example.com")
+			t.Setenv(EnvHTTPTimeout, "")
+			t.Setenv(EnvTokenTTL, "")
+			t.Setenv(EnvDevelopment, "")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := FromEnv()
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("FromEnv() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package appleapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPlaintextHTTP is returned by Client.Do when a request would send the
+// bearer token over plaintext http:// and plaintext is not allowed.
+var ErrPlaintextHTTP = errors.New("refusing to send bearer token over plaintext http")
+
+// ErrH2CNotDevelopment is returned by a client configured with WithH2C when
+// it dials a connection outside development mode.
+var ErrH2CNotDevelopment = errors.New("h2c is only allowed in development mode")
+
+// ErrClockSkewSuspected is returned by ClassifyError when Apple rejects a
+// provider token as expired although it was just issued, which usually
+// means the host clock has drifted and the iat claim is out of range.
+var ErrClockSkewSuspected = errors.New("provider token rejected as expired, clock skew suspected")
+
+// ErrDeviceThrottled is matched by a ThrottleError for an APNs 429 with the
+// reason TooManyRequests: too many notifications were sent to one device token.
+var ErrDeviceThrottled = errors.New("too many requests for the device token")
+
+// ErrProviderTokenUpdatesThrottled is matched by a ThrottleError for an APNs
+// 429 with the reason TooManyProviderTokenUpdates: the provider token was
+// changed too often.
+var ErrProviderTokenUpdatesThrottled = errors.New("provider token updated too often")
+
+// ThrottleError is returned by ClassifyError for APNs 429 responses with a
+// known reason. It wraps the original error, so IsRetryable still reports
+// it as retryable, and matches ErrDeviceThrottled or
+// ErrProviderTokenUpdatesThrottled with errors.Is.
+type ThrottleError struct {
+	Reason     string        // APNs reason, e.g. "TooManyRequests"
+	PerDevice  bool          // Only the device token of the request is throttled, not the whole provider
+	RetryAfter time.Duration // Delay requested by a Retry-After header; 0 if there is none
+	Err        error         // The classified error
+}
+
+// Error implements the error interface.
+func (e *ThrottleError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("throttled (%s, retry after %v): %v", e.Reason, e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("throttled (%s): %v", e.Reason, e.Err)
+}
+
+// Unwrap returns the sentinel matching the reason and the classified error.
+func (e *ThrottleError) Unwrap() []error {
+	if e.PerDevice {
+		return []error{ErrDeviceThrottled, e.Err}
+	}
+	return []error{ErrProviderTokenUpdatesThrottled, e.Err}
+}
+
+// StatusError is returned by the response helpers when the server replies
+// with a non-2xx status code.
+type StatusError struct {
+	StatusCode int         // HTTP status code of the response
+	Header     http.Header // Response headers
+	Body       []byte      // Leading portion of the response body
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	if len(e.Body) == 0 {
+		return fmt.Sprintf("unexpected status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("unexpected status %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// Reason returns the "reason" field of an APNs-style JSON error body, such
+// as "ExpiredProviderToken", or "" if the body has none.
+func (e *StatusError) Reason() string {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if json.Unmarshal(e.Body, &body) != nil {
+		return ""
+	}
+	return body.Reason
+}
+
+// ClassifyError inspects a *StatusError in err's chain and, for failures
+// with a well-known cause, returns an error that wraps both a specific
+// sentinel and err. A 403 with the reason ExpiredProviderToken yields
+// ErrClockSkewSuspected, since the provider signs tokens with the current
+// time and only a skewed clock makes a fresh token look expired. A 429 with
+// the reason TooManyRequests or TooManyProviderTokenUpdates yields a
+// *ThrottleError, so callers can back off a single device rather than all
+// requests. Other errors are returned unchanged.
+func ClassifyError(err error) error {
+	var se *StatusError
+	if !errors.As(err, &se) {
+		return err
+	}
+	switch reason := se.Reason(); {
+	case se.StatusCode == http.StatusForbidden && reason == "ExpiredProviderToken":
+		return fmt.Errorf("%w (check the host clock and NTP sync): %w", ErrClockSkewSuspected, err)
+	case se.StatusCode == http.StatusTooManyRequests && (reason == "TooManyRequests" || reason == "TooManyProviderTokenUpdates"):
+		delay, _ := ParseRetryAfter(&http.Response{Header: se.Header}, time.Now())
+		return &ThrottleError{Reason: reason, PerDevice: reason == "TooManyRequests", RetryAfter: delay, Err: err}
+	}
+	return err
+}
+
+// IsRetryable reports whether err is likely transient, so the request may
+// succeed if sent again. Retryable errors are 429/502/503/504 responses
+// reported as *StatusError, network timeouts, dial failures and connection
+// resets. Context cancellation and deadlines are never retryable.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		return retryableStatus(se.StatusCode)
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	var oe *net.OpError
+	if errors.As(err, &oe) && oe.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryableStatus reports whether a response with the given status code is worth retrying.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
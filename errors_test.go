@@ -0,0 +1,170 @@
+package appleapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://api.example.com", Err: err}
+	}
+
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"nil":                  {err: nil, want: false},
+		"plain error":          {err: errors.New("boom"), want: false},
+		"plaintext rejected":   {err: ErrPlaintextHTTP, want: false},
+		"429":                  {err: &StatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		"502":                  {err: &StatusError{StatusCode: http.StatusBadGateway}, want: true},
+		"503 wrapped":          {err: fmt.Errorf("call: %w", &StatusError{StatusCode: http.StatusServiceUnavailable}), want: true},
+		"504":                  {err: &StatusError{StatusCode: http.StatusGatewayTimeout}, want: true},
+		"400":                  {err: &StatusError{StatusCode: http.StatusBadRequest}, want: false},
+		"403":                  {err: &StatusError{StatusCode: http.StatusForbidden}, want: false},
+		"500":                  {err: &StatusError{StatusCode: http.StatusInternalServerError}, want: false},
+		"context canceled":     {err: urlErr(context.Canceled), want: false},
+		"deadline exceeded":    {err: urlErr(context.DeadlineExceeded), want: false},
+		"network timeout":      {err: urlErr(timeoutError{}), want: true},
+		"dial refused":         {err: urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), want: true},
+		"connection reset":     {err: urlErr(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), want: true},
+		"broken pipe":          {err: urlErr(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}), want: true},
+		"unexpected EOF":       {err: urlErr(io.ErrUnexpectedEOF), want: true},
+		"non-dial op no errno": {err: urlErr(&net.OpError{Op: "read", Net: "tcp", Err: errors.New("use of closed connection")}), want: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusError_Reason(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want string
+	}{
+		"apns reason": {body: `{"reason":"BadDeviceToken"}`, want: "BadDeviceToken"},
+		"no reason":   {body: `{"errors":[]}`, want: ""},
+		"not json":    {body: "Forbidden", want: ""},
+		"empty":       {body: "", want: ""},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			se := &StatusError{StatusCode: http.StatusBadRequest, Body: []byte(tt.body)}
+			if got := se.Reason(); got != tt.want {
+				t.Errorf("Reason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	expired := &StatusError{StatusCode: http.StatusForbidden, Body: []byte(`{"reason":"ExpiredProviderToken"}`)}
+
+	tests := map[string]struct {
+		err       error
+		wantSkew  bool
+		unchanged bool
+	}{
+		"expired provider token": {err: expired, wantSkew: true},
+		"wrapped":                {err: fmt.Errorf("push: %w", expired), wantSkew: true},
+		"other 403 reason":       {err: &StatusError{StatusCode: http.StatusForbidden, Body: []byte(`{"reason":"InvalidProviderToken"}`)}, unchanged: true},
+		"reason on other status": {err: &StatusError{StatusCode: http.StatusBadRequest, Body: []byte(`{"reason":"ExpiredProviderToken"}`)}, unchanged: true},
+		"not a status error":     {err: errors.New("boom"), unchanged: true},
+		"nil":                    {err: nil, unchanged: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ClassifyError(tt.err)
+			if tt.unchanged && got != tt.err {
+				t.Errorf("ClassifyError(%v) = %v, want the error unchanged", tt.err, got)
+			}
+			if errors.Is(got, ErrClockSkewSuspected) != tt.wantSkew {
+				t.Errorf("errors.Is(%v, ErrClockSkewSuspected) = %v, want %v", got, !tt.wantSkew, tt.wantSkew)
+			}
+			var se *StatusError
+			if tt.wantSkew && !errors.As(got, &se) {
+				t.Errorf("classified error %v does not wrap the *StatusError", got)
+			}
+		})
+	}
+}
+
+func TestClassifyError_Throttle(t *testing.T) {
+	tests := map[string]struct {
+		err           error
+		wantSentinel  error
+		wantPerDevice bool
+		wantDelay     time.Duration
+	}{
+		"device throttled": {
+			err:           &StatusError{StatusCode: http.StatusTooManyRequests, Body: []byte(`{"reason":"TooManyRequests"}`)},
+			wantSentinel:  ErrDeviceThrottled,
+			wantPerDevice: true,
+		},
+		"device throttled with hint": {
+			err: &StatusError{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": {"120"}},
+				Body:       []byte(`{"reason":"TooManyRequests"}`),
+			},
+			wantSentinel:  ErrDeviceThrottled,
+			wantPerDevice: true,
+			wantDelay:     2 * time.Minute,
+		},
+		"provider token updates": {
+			err:          fmt.Errorf("push: %w", &StatusError{StatusCode: http.StatusTooManyRequests, Body: []byte(`{"reason":"TooManyProviderTokenUpdates"}`)}),
+			wantSentinel: ErrProviderTokenUpdatesThrottled,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ClassifyError(tt.err)
+			var te *ThrottleError
+			if !errors.As(got, &te) {
+				t.Fatalf("ClassifyError(%v) = %v, want a *ThrottleError", tt.err, got)
+			}
+			if te.PerDevice != tt.wantPerDevice || te.RetryAfter != tt.wantDelay {
+				t.Errorf("ThrottleError = %+v, want PerDevice %v and RetryAfter %v", te, tt.wantPerDevice, tt.wantDelay)
+			}
+			if !errors.Is(got, tt.wantSentinel) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", got, tt.wantSentinel)
+			}
+			if !IsRetryable(got) {
+				t.Errorf("IsRetryable(%v) = false, want true", got)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("classified error %v does not wrap the original", got)
+			}
+		})
+	}
+
+	// A generic 429 is left alone.
+	generic := &StatusError{StatusCode: http.StatusTooManyRequests, Body: []byte("slow down")}
+	if got := ClassifyError(generic); got != error(generic) {
+		t.Errorf("ClassifyError(%v) = %v, want the error unchanged", generic, got)
+	}
+}
@@ -0,0 +1,92 @@
+package appleapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// failoverHost returns the host part of a host given to WithFailoverHosts,
+// which may be a bare host[:port] or a URL.
+func failoverHost(s string) string {
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// isDialError reports whether err means that no connection to the server
+// could be established, so the request was never sent.
+func isDialError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retarget returns a copy of req addressed to host, with the path and query
+// preserved. A Host header that differs from the URL host, such as one set
+// with WithHostHeader, is kept. It reports false if the body cannot be replayed.
+func retarget(req *http.Request, host string) (*http.Request, bool) {
+	next, ok := replay(req)
+	if !ok {
+		return nil, false
+	}
+	if next.Host == "" || next.Host == req.URL.Host {
+		next.Host = host
+	}
+	next.URL.Host = host
+	return next, true
+}
+
+// replay returns a copy of req with a fresh body, so it can be sent again.
+// It reports false if the body cannot be replayed.
+func replay(req *http.Request) (*http.Request, bool) {
+	next := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, false
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		next.Body = body
+	}
+	return next, true
+}
+
+// send performs req and, while connecting fails, retries it against each
+// host configured with WithFailoverHosts. It returns the last request sent.
+func (c *Client) send(ctx context.Context, logger *slog.Logger, req *http.Request) (*http.Request, *http.Response, error) {
+	hc := c.httpClient(ctx)
+	resp, err := hc.Do(req)
+	for _, host := range c.failoverHosts {
+		if err == nil || !isDialError(err) {
+			break
+		}
+		if host == req.URL.Host {
+			continue
+		}
+		next, ok := retarget(req, host)
+		if !ok {
+			break
+		}
+		c.log(ctx, logger, slog.LevelWarn, "Failing over to next host",
+			slog.String("from", req.URL.Host),
+			slog.String("to", host),
+			slog.Any("err", err),
+		)
+		req = next
+		resp, err = hc.Do(req)
+	}
+	return req, resp, err
+}
@@ -0,0 +1,137 @@
+package appleapi
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// unreachableURL returns an http:// URL on which nothing is listening.
+func unreachableURL(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return "http://" + addr
+}
+
+func TestClient_Do_FailoverHosts(t *testing.T) {
+	type seen struct {
+		Path, Query, Body, Host string
+	}
+	var got []seen
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, seen{r.URL.Path, r.URL.RawQuery, string(body), r.Host})
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer secondary.Close()
+	secondaryURL, _ := url.Parse(secondary.URL)
+
+	primary := unreachableURL(t)
+	c, err := NewClient(DefaultHTTPClientInitializer(), primary, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithFailoverHosts(unreachableURL(t), secondary.URL),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, primary+"/3/device/abc?x=1", strings.NewReader(`{"aps":{}}`))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	want := []seen{{Path: "/3/device/abc", Query: "x=1", Body: `{"aps":{}}`, Host: secondaryURL.Host}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("secondary request mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_Do_FailoverHosts_NotOnResponse(t *testing.T) {
+	var primaryHits, secondaryHits int
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryHits++
+	}))
+	defer secondary.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), primary.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithFailoverHosts(secondary.URL),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, primary.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest || primaryHits != 1 || secondaryHits != 0 {
+		t.Errorf("status = %d, primary hits = %d, secondary hits = %d; want 400, 1, 0",
+			resp.StatusCode, primaryHits, secondaryHits)
+	}
+}
+
+func TestClient_Do_FailoverHosts_KeepsHostHeader(t *testing.T) {
+	var host string
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer secondary.Close()
+
+	primary := unreachableURL(t)
+	c, err := NewClient(DefaultHTTPClientInitializer(), primary, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithFailoverHosts(secondary.URL),
+		WithHostHeader("api.example.com"),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, primary, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if host != "api.example.com" {
+		t.Errorf("Host = %q, want %q", host, "api.example.com")
+	}
+}
+
+func TestFailoverHost(t *testing.T) {
+	tests := map[string]string{
+		"api.push.apple.com":                    "api.push.apple.com",
+		"api.push.apple.com:2197":               "api.push.apple.com:2197",
+		"https://api.sandbox.push.apple.com":    "api.sandbox.push.apple.com",
+		"https://api.sandbox.push.apple.com/x/": "api.sandbox.push.apple.com",
+		"":                                      "",
+	}
+	for in, want := range tests {
+		if got := failoverHost(in); got != want {
+			t.Errorf("failoverHost(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
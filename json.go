@@ -0,0 +1,270 @@
+package appleapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxErrorBodySize is the maximum number of bytes of an error response body kept in a StatusError.
+const maxErrorBodySize = 4 << 10
+
+// Page is the envelope of a paginated App Store Connect response.
+type Page[T any] struct {
+	Data  []T `json:"data"`
+	Links struct {
+		Self string `json:"self"`
+		Next string `json:"next"`
+	} `json:"links"`
+	Meta struct {
+		Paging struct {
+			Total int `json:"total"`
+			Limit int `json:"limit"`
+		} `json:"paging"`
+	} `json:"meta"`
+}
+
+// DoJSON sends req with d, typically a *Client, and decodes a 2xx JSON
+// response body into out. A nil out discards the body. 204 No Content and
+// 304 Not Modified responses succeed without decoding, leaving out untouched.
+// Other non-2xx responses are returned as *StatusError.
+func DoJSON(d Doer, req *http.Request, out any) error {
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	resp, err := d.Do(req)
+	if err != nil {
+		return err
+	}
+	defer DrainBody(resp)
+
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	return decodeJSON(resp, out)
+}
+
+// checkStatus returns a *StatusError holding the leading part of the body
+// if resp does not have a 2xx status code.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return &StatusError{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+	}
+	return nil
+}
+
+// decodeJSON decodes the response body into out. A nil out discards the body.
+func decodeJSON(resp *http.Response, out any) error {
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response body: %w", err)
+	}
+	return nil
+}
+
+// GetJSON sends a GET request for path and decodes the JSON response into
+// out as DoJSON does. path is resolved against Client.Host unless it is an
+// absolute URL.
+func GetJSON(ctx context.Context, c *Client, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.resolveURL(path), nil)
+	if err != nil {
+		return err
+	}
+	return DoJSON(c, req, out)
+}
+
+// PostJSON sends in as a JSON request body to path with POST and decodes the
+// JSON response into out as DoJSON does. path is resolved against
+// Client.Host unless it is an absolute URL.
+func PostJSON(ctx context.Context, c *Client, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.resolveURL(path), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return DoJSON(c, req, out)
+}
+
+// ErrUntrustedNextLink is returned by GetPage and GetAll when a response
+// links to a next page on another scheme or host than Client.Host, which
+// would send the bearer token to that host.
+var ErrUntrustedNextLink = errors.New("next page link points to an untrusted host")
+
+// GetPage fetches one page of a paginated resource and returns its items
+// together with the URL of the next page, which is empty on the last page.
+// path is resolved against Client.Host unless it is an absolute URL, so the
+// returned next URL can be passed back as path. When Client.Host is empty, a
+// relative next URL is returned resolved against the requested URL. An
+// absolute next URL must have the scheme and host of Client.Host, or of the
+// requested URL when Client.Host is empty; otherwise GetPage fails with
+// ErrUntrustedNextLink.
+func GetPage[T any](ctx context.Context, c *Client, path string) ([]T, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.resolveURL(path), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	var page Page[T]
+	if err := DoJSON(c, req, &page); err != nil {
+		return nil, "", err
+	}
+	next, err := c.nextLink(req.URL, page.Links.Next)
+	if err != nil {
+		return nil, "", err
+	}
+	return page.Data, next, nil
+}
+
+// GetAll fetches every page of a paginated resource, following next links
+// until the last page. It stops early with the context error if ctx is done.
+func GetAll[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	var all []T
+	for next := path; next != ""; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		items, n, err := GetPage[T](ctx, c, next)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		next = n
+	}
+	return all, nil
+}
+
+// ErrStopStream can be returned by the yield function passed to Stream to
+// stop streaming early. Stream then returns nil.
+var ErrStopStream = errors.New("stop stream")
+
+// Stream fetches path and decodes the elements of the "data" array of the
+// JSON response one at a time, calling yield for each, so large responses
+// are never held in memory as a whole. Streaming stops at the first error
+// returned by yield, which Stream returns unless it is ErrStopStream, and
+// with the context error once ctx is done. path is resolved against
+// Client.Host unless it is an absolute URL. Non-2xx responses are returned
+// as *StatusError.
+func Stream[T any](ctx context.Context, c *Client, path string, yield func(T) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.resolveURL(path), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer DrainBody(resp)
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+
+	err = streamData(ctx, json.NewDecoder(resp.Body), yield)
+	if errors.Is(err, ErrStopStream) {
+		return nil
+	}
+	return err
+}
+
+// streamData walks the top-level JSON object read by dec and calls yield for
+// each element of its "data" array. Other fields are skipped.
+func streamData[T any](ctx context.Context, dec *json.Decoder, yield func(T) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("failed to decode response body: %w", err)
+		}
+		if key, _ := tok.(string); key != "data" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("failed to decode response body: %w", err)
+			}
+			continue
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for dec.More() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var item T
+			if err := dec.Decode(&item); err != nil {
+				return fmt.Errorf("failed to decode response body: %w", err)
+			}
+			if err := yield(item); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expectDelim reads the next token from dec and checks that it is delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to decode response body: %w", err)
+	}
+	if tok != delim {
+		return fmt.Errorf("failed to decode response body: expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+// resolveURL returns path joined to Client.Host, or path itself if it is an absolute URL.
+func (c *Client) resolveURL(path string) string {
+	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") || c.Host == "" {
+		return path
+	}
+	return strings.TrimRight(c.Host, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// nextLink returns the next page link to follow, or ErrUntrustedNextLink if
+// it has another scheme or host than Client.Host, or than reqURL if Host is
+// empty. Relative links are always allowed: they are resolved against
+// Client.Host by GetPage, or here against reqURL if Host is empty.
+func (c *Client) nextLink(reqURL *url.URL, next string) (string, error) {
+	if next == "" || c.resolveURL(next) != next {
+		return next, nil
+	}
+	u, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUntrustedNextLink, err)
+	}
+	base := reqURL
+	if c.Host != "" {
+		if base, err = url.Parse(c.Host); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrUntrustedNextLink, err)
+		}
+	} else {
+		u = reqURL.ResolveReference(u)
+		next = u.String()
+	}
+	if !strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Host, base.Host) {
+		return "", fmt.Errorf("%w: %s", ErrUntrustedNextLink, next)
+	}
+	return next, nil
+}
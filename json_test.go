@@ -0,0 +1,473 @@
+package appleapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testApp struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// newPagedServer serves the given pages in order, linking each page to the next via a cursor.
+func newPagedServer(t *testing.T, pages [][]testApp) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/apps" {
+			http.NotFound(w, r)
+			return
+		}
+		var idx int
+		fmt.Sscan(r.URL.Query().Get("cursor"), &idx)
+
+		page := Page[testApp]{Data: pages[idx]}
+		page.Links.Self = srv.URL + r.URL.RequestURI()
+		if idx+1 < len(pages) {
+			page.Links.Next = fmt.Sprintf("%s/v1/apps?cursor=%d", srv.URL, idx+1)
+		}
+		page.Meta.Paging.Total = len(pages)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGetPage(t *testing.T) {
+	pages := [][]testApp{
+		{{ID: "1", Type: "apps"}, {ID: "2", Type: "apps"}},
+		{{ID: "3", Type: "apps"}},
+	}
+	srv := newPagedServer(t, pages)
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	items, next, err := GetPage[testApp](context.Background(), c, "/v1/apps")
+	if err != nil {
+		t.Fatalf("GetPage failed: %v", err)
+	}
+	if diff := cmp.Diff(pages[0], items); diff != "" {
+		t.Errorf("first page mismatch (-want +got):\n%s", diff)
+	}
+	if want := srv.URL + "/v1/apps?cursor=1"; next != want {
+		t.Errorf("next = %q, want %q", next, want)
+	}
+
+	items, next, err = GetPage[testApp](context.Background(), c, next)
+	if err != nil {
+		t.Fatalf("GetPage failed: %v", err)
+	}
+	if diff := cmp.Diff(pages[1], items); diff != "" {
+		t.Errorf("second page mismatch (-want +got):\n%s", diff)
+	}
+	if next != "" {
+		t.Errorf("next = %q, want empty on the last page", next)
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	pages := [][]testApp{
+		{{ID: "1", Type: "apps"}, {ID: "2", Type: "apps"}},
+		{{ID: "3", Type: "apps"}},
+	}
+	srv := newPagedServer(t, pages)
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	t.Run("all pages", func(t *testing.T) {
+		items, err := GetAll[testApp](context.Background(), c, "v1/apps")
+		if err != nil {
+			t.Fatalf("GetAll failed: %v", err)
+		}
+		want := append(append([]testApp{}, pages[0]...), pages[1]...)
+		if diff := cmp.Diff(want, items); diff != "" {
+			t.Errorf("items mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := GetAll[testApp](ctx, c, "/v1/apps"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("GetAll error = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		_, err := GetAll[testApp](context.Background(), c, "/v1/missing")
+		var se *StatusError
+		if !errors.As(err, &se) {
+			t.Fatalf("GetAll error = %v, want *StatusError", err)
+		}
+		if se.StatusCode != http.StatusNotFound {
+			t.Errorf("StatusCode = %d, want %d", se.StatusCode, http.StatusNotFound)
+		}
+	})
+}
+
+func TestGetAll_UntrustedNextLink(t *testing.T) {
+	var leaked atomic.Bool
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked.Store(true)
+		io.WriteString(w, `{"data":[]}`)
+	}))
+	defer other.Close()
+
+	var next string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := Page[testApp]{Data: []testApp{{ID: "1", Type: "apps"}}}
+		page.Links.Next = next
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+	otherURL, _ := url.Parse(other.URL)
+	srvURL, _ := url.Parse(srv.URL)
+
+	tests := map[string]string{
+		"other host":   "http://attacker.example/v1/apps?cursor=1",
+		"other scheme": "https://" + srvURL.Host + "/v1/apps?cursor=1",
+		"other port":   "http://" + srvURL.Hostname() + ":" + otherURL.Port() + "/v1/apps?cursor=1",
+	}
+
+	for name, link := range tests {
+		t.Run(name, func(t *testing.T) {
+			next = link
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			if _, err := GetAll[testApp](context.Background(), c, "/v1/apps"); !errors.Is(err, ErrUntrustedNextLink) {
+				t.Fatalf("GetAll error = %v, want %v", err, ErrUntrustedNextLink)
+			}
+			if leaked.Load() {
+				t.Fatal("the token was sent to the untrusted host")
+			}
+		})
+	}
+}
+
+func TestGetAll_RelativeNextLink(t *testing.T) {
+	var next string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := Page[testApp]{Data: []testApp{{ID: "2", Type: "apps"}}}
+		if r.URL.Query().Get("cursor") == "" {
+			page = Page[testApp]{Data: []testApp{{ID: "1", Type: "apps"}}}
+			page.Links.Next = next
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		host    string
+		path    string
+		next    string
+		wantErr error
+	}{
+		"client host":            {host: srv.URL, path: "/v1/apps", next: "/v1/apps?cursor=1"},
+		"empty host":             {path: srv.URL + "/v1/apps", next: "/v1/apps?cursor=1"},
+		"empty host, no slash":   {path: srv.URL + "/v1/apps", next: "apps?cursor=1"},
+		"empty host, other host": {path: srv.URL + "/v1/apps", next: "//attacker.example/v1/apps?cursor=1", wantErr: ErrUntrustedNextLink},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			next = tt.next
+			c, err := NewClient(DefaultHTTPClientInitializer(), tt.host, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			items, err := GetAll[testApp](context.Background(), c, tt.path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetAll error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetAll failed: %v", err)
+			}
+			want := []testApp{{ID: "1", Type: "apps"}, {ID: "2", Type: "apps"}}
+			if diff := cmp.Diff(want, items); diff != "" {
+				t.Errorf("items mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeDoer is a Doer that returns canned responses without a network.
+type fakeDoer struct {
+	resp *http.Response
+	err  error
+	reqs []*http.Request
+}
+
+func (f *fakeDoer) Do(req *http.Request) (*http.Response, error) {
+	f.reqs = append(f.reqs, req)
+	return f.resp, f.err
+}
+
+func TestDoJSON_FakeDoer(t *testing.T) {
+	tests := map[string]struct {
+		doer       *fakeDoer
+		want       testApp
+		wantStatus int
+		wantErr    error
+	}{
+		"decodes body": {
+			doer: &fakeDoer{resp: &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"id":"1","type":"apps"}`)),
+			}},
+			want: testApp{ID: "1", Type: "apps"},
+		},
+		"status error": {
+			doer: &fakeDoer{resp: &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       io.NopCloser(strings.NewReader(`not found`)),
+			}},
+			wantStatus: http.StatusNotFound,
+		},
+		"transport error": {
+			doer:    &fakeDoer{err: context.Canceled},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/v1/apps/1", nil)
+			var got testApp
+			err := DoJSON(tt.doer, req, &got)
+			switch {
+			case tt.wantStatus != 0:
+				var statusErr *StatusError
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+					t.Fatalf("DoJSON error = %v, want *StatusError with %d", err, tt.wantStatus)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DoJSON error = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("DoJSON failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("decoded mismatch (-want +got):\n%s", diff)
+			}
+			if got := tt.doer.reqs[0].Header.Get("Accept"); got != "application/json" {
+				t.Errorf("Accept = %q, want %q", got, "application/json")
+			}
+		})
+	}
+}
+
+func TestGetJSON_PostJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/apps/1":
+			io.WriteString(w, `{"id":"1","type":"apps"}`)
+		case "/v1/echo":
+			if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			io.Copy(w, r.Body)
+		case "/no-content":
+			w.WriteHeader(http.StatusNoContent)
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	ctx := context.Background()
+
+	var app testApp
+	if err := GetJSON(ctx, c, "/v1/apps/1", &app); err != nil {
+		t.Fatalf("GetJSON failed: %v", err)
+	}
+	if diff := cmp.Diff(testApp{ID: "1", Type: "apps"}, app); diff != "" {
+		t.Errorf("GetJSON mismatch (-want +got):\n%s", diff)
+	}
+
+	var echoed testApp
+	if err := PostJSON(ctx, c, "/v1/echo", testApp{ID: "2", Type: "apps"}, &echoed); err != nil {
+		t.Fatalf("PostJSON failed: %v", err)
+	}
+	if diff := cmp.Diff(testApp{ID: "2", Type: "apps"}, echoed); diff != "" {
+		t.Errorf("PostJSON mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, path := range []string{"/no-content", "/not-modified"} {
+		t.Run(path, func(t *testing.T) {
+			out := testApp{ID: "unchanged"}
+			if err := GetJSON(ctx, c, path, &out); err != nil {
+				t.Errorf("GetJSON error = %v, want nil", err)
+			}
+			if err := PostJSON(ctx, c, path, struct{}{}, &out); err != nil {
+				t.Errorf("PostJSON error = %v, want nil", err)
+			}
+			if out.ID != "unchanged" {
+				t.Errorf("out = %+v, want it untouched", out)
+			}
+		})
+	}
+}
+
+// newStreamServer serves a "data" array of n apps. It flushes the first
+// batch and then waits for release before writing the rest, so a client can
+// only see the first batch early if it decodes incrementally.
+func newStreamServer(t *testing.T, n, batch int, release <-chan struct{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"links":{"self":"/v1/apps"},"data":[`)
+		for i := range n {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprintf(w, `{"id":"%d","type":"apps"}`, i)
+			if i == batch-1 {
+				w.(http.Flusher).Flush()
+				select {
+				case <-release:
+				case <-r.Context().Done():
+					return
+				case <-time.After(5 * time.Second):
+					return
+				}
+			}
+		}
+		io.WriteString(w, `],"meta":{"paging":{"total":1}}}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStream(t *testing.T) {
+	const total, batch = 20000, 10
+	released := make(chan struct{})
+	close(released)
+
+	t.Run("all items incrementally", func(t *testing.T) {
+		release := make(chan struct{})
+		srv := newStreamServer(t, total, batch, release)
+		c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+
+		var n int
+		err = Stream(context.Background(), c, "/v1/apps", func(app testApp) error {
+			if app.ID != fmt.Sprint(n) {
+				t.Fatalf("item %d has ID %q", n, app.ID)
+			}
+			n++
+			if n == batch {
+				// The rest of the array has not been written yet.
+				close(release)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if n != total {
+			t.Errorf("streamed %d items, want %d", n, total)
+		}
+	})
+
+	t.Run("early stop", func(t *testing.T) {
+		srv := newStreamServer(t, total, batch, released)
+		c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+
+		var n int
+		err = Stream(context.Background(), c, "/v1/apps", func(testApp) error {
+			n++
+			if n == 3 {
+				return ErrStopStream
+			}
+			return nil
+		})
+		if err != nil || n != 3 {
+			t.Errorf("Stream = %v after %d items, want nil after 3", err, n)
+		}
+	})
+
+	t.Run("yield error", func(t *testing.T) {
+		srv := newStreamServer(t, total, batch, released)
+		c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+
+		boom := errors.New("boom")
+		err = Stream(context.Background(), c, "/v1/apps", func(testApp) error { return boom })
+		if !errors.Is(err, boom) {
+			t.Errorf("Stream error = %v, want %v", err, boom)
+		}
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		srv := newStreamServer(t, total, batch, released)
+		c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var n int
+		err = Stream(ctx, c, "/v1/apps", func(testApp) error {
+			n++
+			if n == 2 {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) || n != 2 {
+			t.Errorf("Stream = %v after %d items, want context.Canceled after 2", err, n)
+		}
+	})
+
+	t.Run("status error", func(t *testing.T) {
+		srv := newPagedServer(t, [][]testApp{{}})
+		c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+
+		var se *StatusError
+		err = Stream(context.Background(), c, "/v1/missing", func(testApp) error { return nil })
+		if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+			t.Errorf("Stream error = %v, want *StatusError 404", err)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package appleapi
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+// openMetricsContentType is the media type of the OpenMetrics text format.
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// requestMetrics counts requests sent through a client. The counters are
+// always on; they cost a few atomic additions per request.
+type requestMetrics struct {
+	requests atomic.Uint64    // Calls to Do
+	errors   atomic.Uint64    // Calls to Do that returned an error
+	retries  atomic.Uint64    // Attempts repeated after a retryable status or connection error
+	classes  [6]atomic.Uint64 // Responses by status class, indexed by code/100
+
+	requestBytes  sizeHistogram // Request body sizes, when known
+	responseBytes sizeHistogram // Response body sizes, when known
+
+	byCode bool                   // Count responses by exact status code too
+	mu     sync.Mutex             // Guards codes
+	codes  map[int]*atomic.Uint64 // Responses by exact status code
+}
+
+// WithStatusCodeMetrics makes MetricsHandler report responses by exact
+// status code in addition to the status classes (2xx, 4xx, ...) that are
+// always counted.
+func WithStatusCodeMetrics() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.metrics.byCode = true
+			}
+		},
+		order: StatusCodeMetrics,
+	}
+}
+
+// observe counts a response with the given status code.
+func (m *requestMetrics) observe(code int) {
+	if class := code / 100; class >= 1 && class <= 5 {
+		m.classes[class].Add(1)
+	}
+	if !m.byCode {
+		return
+	}
+	m.mu.Lock()
+	n := m.codes[code]
+	if n == nil {
+		if m.codes == nil {
+			m.codes = make(map[int]*atomic.Uint64)
+		}
+		n = new(atomic.Uint64)
+		m.codes[code] = n
+	}
+	m.mu.Unlock()
+	n.Add(1)
+}
+
+// observeSizes records the body sizes of a request and its response. A body
+// whose length is not known up front, such as a streamed request or a chunked
+// response, is not observed.
+func (m *requestMetrics) observeSizes(req *http.Request, resp *http.Response) {
+	if n, ok := requestBodyLength(req); ok {
+		m.requestBytes.observe(n)
+	}
+	if resp.ContentLength >= 0 {
+		m.responseBytes.observe(resp.ContentLength)
+	}
+}
+
+// sizeBuckets are the upper bounds, in bytes, of the size histogram buckets.
+var sizeBuckets = [...]int64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+// sizeHistogram is a histogram of byte sizes over sizeBuckets. Counts are
+// kept per bucket and made cumulative when written.
+type sizeHistogram struct {
+	counts [len(sizeBuckets) + 1]atomic.Uint64 // The last bucket is +Inf
+	sum    atomic.Uint64
+}
+
+// observe records a size of n bytes.
+func (h *sizeHistogram) observe(n int64) {
+	i, _ := slices.BinarySearch(sizeBuckets[:], n)
+	h.counts[i].Add(1)
+	h.sum.Add(uint64(n))
+}
+
+// snapshot returns a copy of the histogram's counts.
+func (h *sizeHistogram) snapshot() SizeHistogram {
+	s := SizeHistogram{Bounds: slices.Clone(sizeBuckets[:]), Counts: make([]uint64, len(h.counts))}
+	for i := range h.counts {
+		s.Counts[i] = h.counts[i].Load()
+		s.Count += s.Counts[i]
+	}
+	s.Sum = h.sum.Load()
+	return s
+}
+
+// write writes h to w as an OpenMetrics histogram named name.
+func (h *sizeHistogram) write(w io.Writer, name, help string) {
+	s := h.snapshot()
+	fmt.Fprintf(w, "# TYPE %s histogram\n# HELP %s %s\n", name, name, help)
+	var count uint64
+	for i, n := range s.Counts {
+		count += n
+		le := "+Inf"
+		if i < len(s.Bounds) {
+			le = fmt.Sprint(s.Bounds[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, le, count)
+	}
+	fmt.Fprintf(w, "%s_sum %d\n%s_count %d\n", name, s.Sum, name, s.Count)
+}
+
+// SizeHistogram is a snapshot of a histogram of body sizes.
+type SizeHistogram struct {
+	Bounds []int64  // Upper bounds of the buckets, in bytes
+	Counts []uint64 // Observations per bucket, not cumulative; the extra last entry counts sizes above the last bound
+	Sum    uint64   // Total of the observed sizes, in bytes
+	Count  uint64   // Number of observations
+}
+
+// ClientMetrics is a point-in-time copy of a client's counters, as returned
+// by Client.MetricsSnapshot. It holds plain values only, so it can be stored
+// or serialized freely.
+type ClientMetrics struct {
+	Requests         uint64            // Calls to Do
+	Errors           uint64            // Calls to Do that returned an error
+	Retries          uint64            // Attempts repeated after a retryable status or connection error
+	ResponsesByClass map[string]uint64 // Responses by status class: "1xx" to "5xx"
+	ResponsesByCode  map[int]uint64    // Responses by status code; nil unless WithStatusCodeMetrics is set
+	RequestBytes     SizeHistogram     // Request body sizes, when known
+	ResponseBytes    SizeHistogram     // Response body sizes, when known
+	TokenCache       token.CacheStats  // Token cache hits and misses; zero unless the provider counts them
+	Conn             ConnStats         // Connection counters; zero unless WithConnStats is set
+}
+
+// MetricsSnapshot returns a copy of the client's counters, for exporters
+// that do not scrape MetricsHandler. Each counter is read atomically, but
+// requests in flight may be reflected in some counters and not yet in others.
+func (c *Client) MetricsSnapshot() ClientMetrics {
+	m := ClientMetrics{
+		Requests:         c.metrics.requests.Load(),
+		Errors:           c.metrics.errors.Load(),
+		Retries:          c.metrics.retries.Load(),
+		ResponsesByClass: make(map[string]uint64, 5),
+		RequestBytes:     c.metrics.requestBytes.snapshot(),
+		ResponseBytes:    c.metrics.responseBytes.snapshot(),
+	}
+	for class := 1; class <= 5; class++ {
+		m.ResponsesByClass[fmt.Sprintf("%dxx", class)] = c.metrics.classes[class].Load()
+	}
+	if c.metrics.byCode {
+		m.ResponsesByCode = c.metrics.statusCodes()
+	}
+	if p, ok := c.TokenProvider.(interface{ CacheStats() token.CacheStats }); ok {
+		m.TokenCache = p.CacheStats()
+	}
+	if c.connStats != nil {
+		m.Conn = c.ConnStats()
+	}
+	return m
+}
+
+// statusCodes returns the number of responses by exact status code.
+func (m *requestMetrics) statusCodes() map[int]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[int]uint64, len(m.codes))
+	for code, n := range m.codes {
+		counts[code] = n.Load()
+	}
+	return counts
+}
+
+// MetricsHandler returns an http.Handler that renders the client's counters
+// in the OpenMetrics text format, so they can be scraped from a /metrics
+// endpoint without a Prometheus client library. It reports requests, errors,
+// retries and responses by status class (and by code with
+// WithStatusCodeMetrics), histograms of request and response body sizes,
+// token cache hits and misses when the token provider counts them,
+// as *token.TokenProvider does, and connection counters when WithConnStats
+// is set.
+func (c *Client) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", openMetricsContentType)
+		c.writeOpenMetrics(w)
+	})
+}
+
+// writeOpenMetrics writes the client's counters to w in OpenMetrics text format.
+func (c *Client) writeOpenMetrics(w io.Writer) {
+	counter := func(name, help string, v any) {
+		fmt.Fprintf(w, "# TYPE %s counter\n# HELP %s %s\n%s_total %v\n", name, name, help, name, v)
+	}
+
+	counter("appleapi_requests", "Requests sent with Do.", c.metrics.requests.Load())
+	counter("appleapi_request_errors", "Requests for which Do returned an error.", c.metrics.errors.Load())
+	counter("appleapi_retries", "Attempts repeated after a retryable status or connection error.", c.metrics.retries.Load())
+	fmt.Fprintf(w, "# TYPE appleapi_responses counter\n# HELP appleapi_responses Responses by status class.\n")
+	for class := 1; class <= 5; class++ {
+		fmt.Fprintf(w, "appleapi_responses_total{class=\"%dxx\"} %d\n", class, c.metrics.classes[class].Load())
+	}
+	if c.metrics.byCode {
+		codes := c.metrics.statusCodes()
+		fmt.Fprintf(w, "# TYPE appleapi_responses_by_code counter\n# HELP appleapi_responses_by_code Responses by status code.\n")
+		for _, code := range slices.Sorted(maps.Keys(codes)) {
+			fmt.Fprintf(w, "appleapi_responses_by_code_total{code=\"%d\"} %d\n", code, codes[code])
+		}
+	}
+	c.metrics.requestBytes.write(w, "appleapi_request_body_bytes", "Request body sizes, when known.")
+	c.metrics.responseBytes.write(w, "appleapi_response_body_bytes", "Response body sizes, when known.")
+	if p, ok := c.TokenProvider.(interface{ CacheStats() token.CacheStats }); ok {
+		s := p.CacheStats()
+		counter("appleapi_token_cache_hits", "Tokens served from the cache.", s.Hits)
+		counter("appleapi_token_cache_misses", "Tokens that had to be signed.", s.Misses)
+	}
+	if c.connStats != nil {
+		s := c.ConnStats()
+		counter("appleapi_new_connections", "Requests that dialed a new connection.", s.NewConns)
+		counter("appleapi_reused_connections", "Requests that reused a pooled connection.", s.ReusedConns)
+		counter("appleapi_tls_handshakes", "Successful TLS handshakes.", s.TLSHandshakes)
+		counter("appleapi_tls_handshake_seconds", "Time spent in successful TLS handshakes.", s.TLSHandshakeTime.Seconds())
+	}
+	io.WriteString(w, "# EOF\n")
+}
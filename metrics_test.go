@@ -0,0 +1,272 @@
+package appleapi
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+func TestClient_MetricsHandler(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, token.NewProvider("KEYID", "TEAMID", priv),
+		WithAllowPlaintext(),
+		WithRetry(2, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for range 3 {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		DrainBody(resp)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:0", nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected an error for an unreachable host")
+	}
+
+	rec := httptest.NewRecorder()
+	c.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/openmetrics-text") {
+		t.Errorf("Content-Type = %q, want application/openmetrics-text", got)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	text := string(body)
+
+	for _, line := range []string{
+		"# TYPE appleapi_requests counter",
+		"appleapi_requests_total 4",
+		"appleapi_request_errors_total 1",
+		"appleapi_retries_total 1",
+		"appleapi_token_cache_hits_total 3",
+		"appleapi_token_cache_misses_total 1",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("metrics missing line %q:\n%s", line, text)
+		}
+	}
+	if !strings.HasSuffix(text, "# EOF\n") {
+		t.Errorf("metrics should end with # EOF:\n%s", text)
+	}
+	if strings.Contains(text, "appleapi_new_connections") {
+		t.Errorf("connection metrics reported without WithConnStats:\n%s", text)
+	}
+}
+
+func TestClient_MetricsHandler_StatusCodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		opts      []Option
+		wantLines []string
+		wantCodes bool
+	}{
+		"classes only": {
+			opts: []Option{WithAllowPlaintext()},
+			wantLines: []string{
+				`appleapi_responses_total{class="1xx"} 0`,
+				`appleapi_responses_total{class="2xx"} 3`,
+				`appleapi_responses_total{class="3xx"} 0`,
+				`appleapi_responses_total{class="4xx"} 1`,
+				`appleapi_responses_total{class="5xx"} 2`,
+			},
+		},
+		"with status codes": {
+			opts: []Option{WithAllowPlaintext(), WithStatusCodeMetrics()},
+			wantLines: []string{
+				`appleapi_responses_total{class="2xx"} 3`,
+				"# TYPE appleapi_responses_by_code counter",
+				`appleapi_responses_by_code_total{code="200"} 2` + "\n" +
+					`appleapi_responses_by_code_total{code="204"} 1` + "\n" +
+					`appleapi_responses_by_code_total{code="404"} 1` + "\n" +
+					`appleapi_responses_by_code_total{code="500"} 1` + "\n" +
+					`appleapi_responses_by_code_total{code="503"} 1`,
+			},
+			wantCodes: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			for _, code := range []int{200, 503, 204, 404, 200, 500} {
+				req, _ := http.NewRequest(http.MethodGet, srv.URL+"/"+strconv.Itoa(code), nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do failed: %v", err)
+				}
+				DrainBody(resp)
+			}
+
+			rec := httptest.NewRecorder()
+			c.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			text := rec.Body.String()
+			for _, line := range tt.wantLines {
+				if !strings.Contains(text, line+"\n") {
+					t.Errorf("metrics missing %q:\n%s", line, text)
+				}
+			}
+			if got := strings.Contains(text, "appleapi_responses_by_code"); got != tt.wantCodes {
+				t.Errorf("per-code metrics reported = %v, want %v:\n%s", got, tt.wantCodes, text)
+			}
+		})
+	}
+}
+
+func TestClient_MetricsHandler_BodySizes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		body = bytes.Repeat(body, 2)
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	for _, tc := range []struct {
+		path string
+		body string
+	}{
+		{path: "/", body: strings.Repeat("a", 100)},
+		{path: "/", body: strings.Repeat("b", 2000)},
+		// The response length is not known up front, so only the request is observed.
+		{path: "/chunked", body: strings.Repeat("c", 10)},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+tc.path, strings.NewReader(tc.body))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		DrainBody(resp)
+	}
+
+	rec := httptest.NewRecorder()
+	c.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	text := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE appleapi_request_body_bytes histogram",
+		`appleapi_request_body_bytes_bucket{le="256"} 2`,
+		`appleapi_request_body_bytes_bucket{le="1024"} 2`,
+		`appleapi_request_body_bytes_bucket{le="4096"} 3`,
+		`appleapi_request_body_bytes_bucket{le="+Inf"} 3`,
+		"appleapi_request_body_bytes_sum 2110",
+		"appleapi_request_body_bytes_count 3",
+		"# TYPE appleapi_response_body_bytes histogram",
+		`appleapi_response_body_bytes_bucket{le="256"} 1`,
+		`appleapi_response_body_bytes_bucket{le="4096"} 2`,
+		"appleapi_response_body_bytes_sum 4200",
+		"appleapi_response_body_bytes_count 2",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, text)
+		}
+	}
+}
+
+func TestClient_MetricsSnapshot(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithStatusCodeMetrics(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if diff := cmp.Diff(uint64(0), c.MetricsSnapshot().Requests); diff != "" {
+		t.Errorf("requests before any activity (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		body         io.Reader
+	}{
+		{method: http.MethodGet, path: "/"},
+		{method: http.MethodPost, path: "/", body: strings.NewReader("hello")},
+		{method: http.MethodGet, path: "/missing"},
+	} {
+		req, _ := http.NewRequest(tc.method, srv.URL+tc.path, tc.body)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		DrainBody(resp)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:0", nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected an error for an unreachable host")
+	}
+
+	sizes := func(counts []uint64, sum uint64) SizeHistogram {
+		h := SizeHistogram{Bounds: sizeBuckets[:], Counts: make([]uint64, len(sizeBuckets)+1), Sum: sum}
+		for i, n := range counts {
+			h.Counts[i] = n
+			h.Count += n
+		}
+		return h
+	}
+	want := ClientMetrics{
+		Requests:         4,
+		Errors:           1,
+		ResponsesByClass: map[string]uint64{"1xx": 0, "2xx": 2, "3xx": 0, "4xx": 1, "5xx": 0},
+		ResponsesByCode:  map[int]uint64{200: 2, 404: 1},
+		RequestBytes:     sizes([]uint64{3}, 5),
+		ResponseBytes:    sizes([]uint64{3}, 4),
+	}
+	got := c.MetricsSnapshot()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("snapshot mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := json.Marshal(got); err != nil {
+		t.Errorf("failed to marshal snapshot: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package appleapi
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+// authProxy is a reverse proxy that authenticates proxied requests.
+type authProxy struct {
+	proxy    *httputil.ReverseProxy
+	provider token.Provider
+	logs     *Client // carries the logging options; used only to log
+}
+
+// NewAuthProxy returns an http.Handler that reverse-proxies requests to
+// target, for example as a local sidecar in front of an Apple API. Any
+// Authorization header sent by the caller is replaced with a bearer token
+// from p, so a caching provider such as *token.TokenProvider signs a new
+// token only when the cached one expires. Paths are joined to target's path.
+// If no token can be obtained or the upstream cannot be reached, the proxy
+// responds with 502 Bad Gateway.
+//
+// Of opts, only the logging options WithLogger, WithLogLevel and
+// WithContextLogger apply; as with NewClient, nothing is logged unless
+// WithLogger is given.
+func NewAuthProxy(target *url.URL, p token.Provider, opts ...Option) http.Handler {
+	h := &authProxy{
+		provider: p,
+		logs:     &Client{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+	}
+	sort.SliceStable(opts, func(i, j int) bool {
+		return opts[i].order < opts[j].order
+	})
+	for _, opt := range opts {
+		opt.f(h.logs)
+	}
+	h.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			h.logs.log(r.Context(), h.logs.requestLogger(r.Context()), slog.LevelError, "Proxied request failed", slog.Any("err", err))
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *authProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	bearer, err := getToken(ctx, h.provider)
+	if err != nil {
+		h.logs.log(ctx, h.logs.requestLogger(ctx), slog.LevelError, "Failed to get token for proxied request", slog.Any("err", err))
+		http.Error(w, "failed to get token", http.StatusBadGateway)
+		return
+	}
+	in := *r
+	in.Header = r.Header.Clone()
+	in.Header.Set("Authorization", "Bearer "+bearer)
+	h.proxy.ServeHTTP(w, &in)
+}
@@ -0,0 +1,100 @@
+package appleapi
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAuthProxy(t *testing.T) {
+	var gotAuth, gotPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		io.WriteString(w, "upstream")
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL + "/api")
+	proxy := httptest.NewServer(NewAuthProxy(target, &MockTokenProvider{token: "tok"}))
+	defer proxy.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, proxy.URL+"/v1/apps", nil)
+	req.Header.Set("Authorization", "Bearer caller-secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "upstream" {
+		t.Errorf("body = %q, want %q", body, "upstream")
+	}
+	if gotAuth != "Bearer tok" {
+		t.Errorf("forwarded Authorization = %q, want %q", gotAuth, "Bearer tok")
+	}
+	if gotPath != "/api/v1/apps" {
+		t.Errorf("forwarded path = %q, want %q", gotPath, "/api/v1/apps")
+	}
+}
+
+func TestAuthProxy_TokenError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach the upstream")
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+
+	tests := map[string]struct {
+		level slog.Level
+		want  []string
+	}{
+		"logged":   {level: slog.LevelInfo, want: []string{"Failed to get token for proxied request"}},
+		"filtered": {level: slog.LevelError + 1},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := newRecordHandler()
+			proxy := NewAuthProxy(target, &MockTokenProvider{err: errors.New("no key")},
+				WithLogger(slog.New(handler)),
+				WithLogLevel(tt.level),
+			)
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/apps", nil))
+
+			if rec.Code != http.StatusBadGateway {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadGateway)
+			}
+			if diff := cmp.Diff(tt.want, handler.Messages()); diff != "" {
+				t.Errorf("log messages mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAuthProxy_UpstreamError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	target, _ := url.Parse(upstream.URL)
+	upstream.Close()
+
+	handler := newRecordHandler()
+	rec := httptest.NewRecorder()
+	NewAuthProxy(target, &MockTokenProvider{token: "tok"}, WithLogger(slog.New(handler))).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/apps", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+	if diff := cmp.Diff([]string{"Proxied request failed"}, handler.Messages()); diff != "" {
+		t.Errorf("log messages mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,97 @@
+package appleapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxDrainSize is the maximum number of unread bytes DrainBody discards
+// before closing a body. Larger remainders are cheaper to abandon together
+// with their connection than to read.
+const maxDrainSize = 256 << 10
+
+// DrainBody discards up to 256 KiB of the unread response body and closes it,
+// so the underlying connection can be reused for later requests. It is safe
+// to call with a nil response or body.
+func DrainBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	io.CopyN(io.Discard, resp.Body, maxDrainSize)
+	resp.Body.Close()
+}
+
+// bufferedBody is a response body held in memory that can be rewound.
+type bufferedBody struct {
+	*bytes.Reader
+}
+
+// Close implements io.Closer. The data stays readable after Close.
+func (b *bufferedBody) Close() error { return nil }
+
+// rewind resets a buffered response body to its start.
+// It reports false if the body is not buffered.
+func rewind(resp *http.Response) bool {
+	b, ok := resp.Body.(*bufferedBody)
+	if ok {
+		b.Seek(0, io.SeekStart)
+	}
+	return ok
+}
+
+// bufferResponse reads up to limit bytes of the response body into memory.
+// A body within the limit is replaced with a rewindable one; a larger body
+// is left streaming, with the already-read prefix put back in front of it.
+func bufferResponse(resp *http.Response, limit int64) error {
+	orig := resp.Body
+	data, err := io.ReadAll(io.LimitReader(orig, limit+1))
+	if err != nil {
+		orig.Close()
+		return fmt.Errorf("failed to buffer response body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), orig), orig}
+		return nil
+	}
+	orig.Close()
+	resp.Body = &bufferedBody{bytes.NewReader(data)}
+	return nil
+}
+
+// processResponse buffers the response body if configured and runs the
+// response interceptors, then the validators, in order. A buffered body is
+// rewound before each interceptor and before the response is returned to the
+// caller.
+func (c *Client) processResponse(resp *http.Response) error {
+	if c.bufferLimit > 0 {
+		if err := bufferResponse(resp, c.bufferLimit); err != nil {
+			return err
+		}
+	}
+	for _, f := range c.interceptors {
+		rewind(resp)
+		if err := f(resp); err != nil {
+			DrainBody(resp)
+			return err
+		}
+	}
+	rewind(resp)
+	if len(c.validators) > 0 {
+		view := *resp
+		view.Header = resp.Header.Clone()
+		view.Trailer = resp.Trailer.Clone()
+		view.Body = http.NoBody
+		for _, f := range c.validators {
+			if err := f(&view); err != nil {
+				DrainBody(resp)
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package appleapi
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClient_Do_ResponseBodyBuffering(t *testing.T) {
+	const body = `{"data":[{"id":"1"},{"id":"2"}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		limit           int64
+		wantInterceptor string
+		wantCaller      string
+	}{
+		"buffered": {
+			limit:           1 << 10,
+			wantInterceptor: body,
+			wantCaller:      body,
+		},
+		"larger than limit": {
+			limit:           8,
+			wantInterceptor: body,
+			wantCaller:      "",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var seen string
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+				WithAllowPlaintext(),
+				WithResponseBodyBuffering(tt.limit),
+				WithResponseInterceptor(func(resp *http.Response) error {
+					b, err := io.ReadAll(resp.Body)
+					seen = string(b)
+					return err
+				}),
+			)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			if diff := cmp.Diff(tt.wantInterceptor, seen); diff != "" {
+				t.Errorf("interceptor body mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantCaller, string(got)); diff != "" {
+				t.Errorf("caller body mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClient_Do_ResponseInterceptorError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	errRejected := errors.New("rejected")
+	var calls []string
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithResponseInterceptor(func(*http.Response) error {
+			calls = append(calls, "first")
+			return errRejected
+		}),
+		WithResponseInterceptor(func(*http.Response) error {
+			calls = append(calls, "second")
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("Do error = %v, want %v", err, errRejected)
+	}
+	if resp != nil {
+		t.Error("expected nil response on interceptor error")
+	}
+	if diff := cmp.Diff([]string{"first"}, calls); diff != "" {
+		t.Errorf("interceptor calls mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_Do_ResponseValidator(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tagged" {
+			w.Header().Set("Apns-Id", "id-1")
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	errMissing := errors.New("missing apns-id")
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithResponseValidator(func(resp *http.Response) error {
+			resp.Header.Set("X-Mutated", "1")
+			if resp.Header.Get("Apns-Id") == "" {
+				return errMissing
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	t.Run("header missing", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if !errors.Is(err, errMissing) {
+			t.Fatalf("Do error = %v, want %v", err, errMissing)
+		}
+		if resp != nil {
+			t.Error("expected nil response on validation error")
+		}
+	})
+
+	t.Run("header present", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/tagged", nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(body) != "ok" {
+			t.Errorf("body = %q, %v; want %q", body, err, "ok")
+		}
+		if got := resp.Header.Get("X-Mutated"); got != "" {
+			t.Errorf("validator mutated the response header: X-Mutated = %q", got)
+		}
+	})
+}
+
+func TestBufferResponse_Rewind(t *testing.T) {
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader("hello"))}
+	if err := bufferResponse(resp, 16); err != nil {
+		t.Fatalf("bufferResponse failed: %v", err)
+	}
+	for range 2 {
+		b, _ := io.ReadAll(resp.Body)
+		if string(b) != "hello" {
+			t.Fatalf("body = %q, want %q", b, "hello")
+		}
+		if !rewind(resp) {
+			t.Fatal("expected a rewindable body")
+		}
+	}
+}
+
+func TestDrainBody_ReusesConnection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Send the tail of the body late, so it is still unread when the
+		// client closes the body.
+		io.WriteString(w, strings.Repeat("x", 16<<10))
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, strings.Repeat("x", 16<<10))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var reused []bool
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithClientTrace(func(*slog.Logger) *httptrace.ClientTrace {
+			return &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					mu.Lock()
+					defer mu.Unlock()
+					reused = append(reused, info.Reused)
+				},
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for range 2 {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		// Read only part of the body before draining it.
+		io.ReadFull(resp.Body, make([]byte, 16))
+		DrainBody(resp)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := cmp.Diff([]bool{false, true}, reused); diff != "" {
+		t.Errorf("reused mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDrainBody_Nil(t *testing.T) {
+	DrainBody(nil)
+	DrainBody(&http.Response{})
+}
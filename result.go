@@ -0,0 +1,88 @@
+package appleapi
+
+import (
+	"io"
+	"net/http"
+)
+
+// Result is the outcome of Client.Send. Its terminal methods, JSON and Bytes,
+// read and close the response body; Status and OK do not, so a Result that
+// is only inspected through them must be closed with Close.
+type Result struct {
+	resp *http.Response
+	err  error
+}
+
+// Send is like Do but returns a Result that wraps the response or error.
+// Non-2xx responses are not errors at this point; JSON and Bytes report them
+// as *StatusError.
+func (c *Client) Send(req *http.Request) Result {
+	resp, err := c.Do(req)
+	return Result{resp: resp, err: err}
+}
+
+// Err returns the error from sending the request, if any.
+func (r Result) Err() error {
+	return r.err
+}
+
+// Response returns the underlying response, which is nil if sending failed.
+func (r Result) Response() *http.Response {
+	return r.resp
+}
+
+// Status returns the HTTP status code, or 0 if sending failed.
+func (r Result) Status() int {
+	if r.resp == nil {
+		return 0
+	}
+	return r.resp.StatusCode
+}
+
+// OK reports whether the request was sent and the status code is 2xx.
+func (r Result) OK() bool {
+	return r.err == nil && r.Status() >= 200 && r.Status() <= 299
+}
+
+// JSON decodes a 2xx JSON response body into out and closes the body.
+// A nil out discards the body. Non-2xx responses are returned as *StatusError.
+func (r Result) JSON(out any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer DrainBody(r.resp)
+	if err := checkStatus(r.resp); err != nil {
+		return err
+	}
+	return decodeJSON(r.resp, out)
+}
+
+// Bytes reads and closes a 2xx response body. Non-2xx responses are
+// returned as *StatusError.
+func (r Result) Bytes() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	defer DrainBody(r.resp)
+	if err := checkStatus(r.resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r.resp.Body)
+}
+
+// Trailer returns the response trailers, or nil if sending failed.
+// Trailers arrive after the body, so they are only populated once the body
+// has been read to the end by JSON, Bytes or Close; before that the map
+// holds at most the announced keys with nil values.
+func (r Result) Trailer() http.Header {
+	if r.resp == nil {
+		return nil
+	}
+	return r.resp.Trailer
+}
+
+// Close drains and closes the response body. It is a no-op if sending
+// failed or the body was already consumed by JSON or Bytes.
+func (r Result) Close() {
+	DrainBody(r.resp)
+}
@@ -0,0 +1,147 @@
+package appleapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClient_Send(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			io.WriteString(w, `{"id":"1"}`)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"errors":[]}`)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	send := func(path string) Result {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		return c.Send(req)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var got struct{ ID string }
+		if err := send("/ok").JSON(&got); err != nil {
+			t.Fatalf("JSON failed: %v", err)
+		}
+		if got.ID != "1" {
+			t.Errorf("ID = %q, want %q", got.ID, "1")
+		}
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		got, err := send("/ok").Bytes()
+		if err != nil {
+			t.Fatalf("Bytes failed: %v", err)
+		}
+		if diff := cmp.Diff(`{"id":"1"}`, string(got)); diff != "" {
+			t.Errorf("Bytes mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("Status and OK", func(t *testing.T) {
+		ok := send("/ok")
+		defer ok.Close()
+		if ok.Status() != http.StatusOK || !ok.OK() {
+			t.Errorf("Status() = %d, OK() = %v; want 200, true", ok.Status(), ok.OK())
+		}
+		missing := send("/missing")
+		defer missing.Close()
+		if missing.Status() != http.StatusNotFound || missing.OK() {
+			t.Errorf("Status() = %d, OK() = %v; want 404, false", missing.Status(), missing.OK())
+		}
+	})
+
+	t.Run("non-2xx", func(t *testing.T) {
+		var statusErr *StatusError
+		if err := send("/missing").JSON(nil); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			t.Errorf("JSON error = %v, want *StatusError with 404", err)
+		}
+		if _, err := send("/missing").Bytes(); !errors.As(err, &statusErr) || string(statusErr.Body) != `{"errors":[]}` {
+			t.Errorf("Bytes error = %v, want *StatusError with body", err)
+		}
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		tokenErr := errors.New("no token")
+		c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{err: tokenErr}, WithAllowPlaintext())
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ok", nil)
+		r := c.Send(req)
+		defer r.Close()
+		if !errors.Is(r.Err(), tokenErr) || r.Status() != 0 || r.OK() || r.Response() != nil {
+			t.Errorf("Result = {err: %v, status: %d}, want the token error and status 0", r.Err(), r.Status())
+		}
+		if err := r.JSON(nil); !errors.Is(err, tokenErr) {
+			t.Errorf("JSON error = %v, want %v", err, tokenErr)
+		}
+		if _, err := r.Bytes(); !errors.Is(err, tokenErr) {
+			t.Errorf("Bytes error = %v, want %v", err, tokenErr)
+		}
+	})
+}
+
+func TestResult_Trailer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1"}`)
+		w.(http.Flusher).Flush()
+		w.Header().Set("X-Checksum", "abc123")
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"}, WithAllowPlaintext())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	consume := map[string]func(Result) error{
+		"JSON": func(r Result) error {
+			var v struct{ ID string }
+			return r.JSON(&v)
+		},
+		"Bytes": func(r Result) error {
+			_, err := r.Bytes()
+			return err
+		},
+		"Close": func(r Result) error {
+			r.Close()
+			return nil
+		},
+	}
+
+	for name, f := range consume {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			res := c.Send(req)
+			if got := res.Trailer().Get("X-Checksum"); got != "" {
+				t.Errorf("trailer before reading the body = %q, want empty", got)
+			}
+			if err := f(res); err != nil {
+				t.Fatalf("%s failed: %v", name, err)
+			}
+			if got := res.Trailer().Get("X-Checksum"); got != "abc123" {
+				t.Errorf("trailer = %q, want %q", got, "abc123")
+			}
+		})
+	}
+
+	if got := (Result{err: errors.New("failed")}).Trailer(); got != nil {
+		t.Errorf("Trailer() of a failed result = %v, want nil", got)
+	}
+}
@@ -0,0 +1,277 @@
+package appleapi
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseRetryAfter returns the delay requested by the Retry-After header of
+// resp, which may be a number of seconds or an HTTP date. A date is measured
+// from now, and a date in the past yields zero. It reports false if the
+// header is absent or cannot be parsed.
+func ParseRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// WithRetry retries requests answered with 429, 502, 503 or 504 (see
+// WithRetryableStatusCodes) up to maxAttempts times in total, including the
+// first. The delay before each retry is taken from the Retry-After header
+// when present and otherwise computed from base by the backoff strategy
+// (ExponentialFullJitter unless set with WithBackoffStrategy), up to the cap
+// set with WithMaxRetryDelay. Requests whose body cannot be replayed are not
+// retried.
+func WithRetry(maxAttempts int, base time.Duration) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && maxAttempts > 1 && base >= 0 {
+				c.retryAttempts = maxAttempts
+				c.retryBase = base
+			}
+		},
+		order: Retry,
+	}
+}
+
+// WithRetryableStatusCodes replaces the status codes retried by WithRetry,
+// which default to 429, 502, 503 and 504, for example to add 408 or to stop
+// retrying 429. Codes outside 400-599 are ignored; calling it without codes
+// disables retrying on status codes.
+func WithRetryableStatusCodes(codes ...int) Option {
+	return Option{
+		f: func(c *Client) {
+			if c == nil {
+				return
+			}
+			c.retryStatuses = make([]int, 0, len(codes))
+			for _, code := range codes {
+				if code >= 400 && code <= 599 {
+					c.retryStatuses = append(c.retryStatuses, code)
+				}
+			}
+		},
+		order: RetryableStatusCodes,
+	}
+}
+
+// shouldRetryStatus reports whether a response with the given status code is retried.
+func (c *Client) shouldRetryStatus(code int) bool {
+	if c.retryStatuses == nil {
+		return retryableStatus(code)
+	}
+	return slices.Contains(c.retryStatuses, code)
+}
+
+// BackoffStrategy computes the delay before retry number attempt (starting
+// at 1) from the base delay given to WithRetry. max is the cap set with
+// WithMaxRetryDelay, or 0 if the delay is uncapped; the result must not
+// exceed a non-zero max.
+type BackoffStrategy func(attempt int, base, max time.Duration) time.Duration
+
+// WithBackoffStrategy sets the strategy WithRetry uses to compute the delay
+// between attempts when the response has no Retry-After header. The default
+// is ExponentialFullJitter.
+func WithBackoffStrategy(strategy BackoffStrategy) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && strategy != nil {
+				c.retryBackoff = strategy
+			}
+		},
+		order: Backoff,
+	}
+}
+
+// Exponential doubles the delay from base on every attempt, without jitter.
+// Clients retrying in lockstep stay synchronized, so prefer a jittered
+// strategy unless the delays must be predictable.
+func Exponential(attempt int, base, max time.Duration) time.Duration {
+	d := exponential(attempt, base, 2)
+	if max > 0 {
+		d = min(d, max)
+	}
+	return d
+}
+
+// ExponentialFullJitter waits a random delay between zero and the
+// exponential delay, base doubled on every attempt and capped at max. It is
+// the "full jitter" strategy and spreads retries of many clients evenly.
+func ExponentialFullJitter(attempt int, base, max time.Duration) time.Duration {
+	return randomBetween(0, Exponential(attempt, base, max))
+}
+
+// DecorrelatedJitter waits a random delay between base and base*3^attempt,
+// capped at max. It approximates the "decorrelated jitter" strategy, which
+// derives the bound from the previous delay, without keeping state between
+// attempts.
+func DecorrelatedJitter(attempt int, base, max time.Duration) time.Duration {
+	upper := exponential(attempt+1, base, 3)
+	if max > 0 {
+		upper = min(upper, max)
+	}
+	return randomBetween(min(base, upper), upper)
+}
+
+// exponential returns base*factor^(attempt-1), saturating instead of overflowing.
+func exponential(attempt int, base time.Duration, factor int64) time.Duration {
+	d := base
+	for i := 1; i < attempt && d > 0; i++ {
+		if d > math.MaxInt64/time.Duration(factor) {
+			return math.MaxInt64
+		}
+		d *= time.Duration(factor)
+	}
+	return d
+}
+
+// randomBetween returns a random duration in [lo, hi].
+func randomBetween(lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	if hi-lo == math.MaxInt64 {
+		return lo + time.Duration(rand.Int64N(int64(hi-lo)))
+	}
+	return lo + time.Duration(rand.Int64N(int64(hi-lo)+1))
+}
+
+// WithMaxRetryDelay caps the backoff computed by WithRetry at d, so that the
+// growing delay stops once it reaches d. A delay requested with
+// Retry-After is honored even if it exceeds d, unless WithCapRetryAfter is
+// also set. A d of zero or less leaves the backoff uncapped.
+func WithMaxRetryDelay(d time.Duration) Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil && d > 0 {
+				c.retryMax = d
+			}
+		},
+		order: MaxRetryDelay,
+	}
+}
+
+// WithCapRetryAfter applies the WithMaxRetryDelay cap to delays requested
+// with Retry-After too, for callers that prefer failing over waiting longer
+// than the cap.
+func WithCapRetryAfter() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.capRetryAfter = true
+			}
+		},
+		order: MaxRetryDelay,
+	}
+}
+
+// WithConnErrorRetry makes the retries configured with WithRetry cover
+// requests that failed without a response because of a transient connection
+// error, such as a refused dial or a connection reset, as classified by
+// IsRetryable. As with status retries, only requests whose body can be
+// replayed are retried.
+func WithConnErrorRetry() Option {
+	return Option{
+		f: func(c *Client) {
+			if c != nil {
+				c.retryConnErrors = true
+			}
+		},
+		order: ConnErrorRetry,
+	}
+}
+
+// retryDelay returns how long to wait before retry number attempt (starting
+// at 1) of a request answered with resp, which is nil after a connection error.
+func (c *Client) retryDelay(attempt int, resp *http.Response) time.Duration {
+	if d, ok := ParseRetryAfter(resp, time.Now()); ok {
+		if c.capRetryAfter && c.retryMax > 0 {
+			return min(d, c.retryMax)
+		}
+		return d
+	}
+	strategy := c.retryBackoff
+	if strategy == nil {
+		strategy = ExponentialFullJitter
+	}
+	return strategy(attempt, c.retryBase, c.retryMax)
+}
+
+// sendWithRetry performs req with send and retries it as configured by
+// WithRetry. Requests marked with ContextStreamingBody are sent only once.
+// It returns the last request sent.
+func (c *Client) sendWithRetry(ctx context.Context, logger *slog.Logger, req *http.Request) (*http.Request, *http.Response, error) {
+	req, resp, err := c.send(ctx, logger, req)
+	if streamingBody(ctx) {
+		return req, resp, err
+	}
+	for attempt := 1; attempt < c.retryAttempts; attempt++ {
+		if err != nil {
+			if !c.retryConnErrors || !IsRetryable(err) {
+				break
+			}
+		} else if !c.shouldRetryStatus(resp.StatusCode) {
+			break
+		}
+		next, ok := replay(req)
+		if !ok {
+			break
+		}
+		delay := c.retryDelay(attempt, resp)
+		DrainBody(resp)
+		cause := slog.Any("err", err)
+		if err == nil {
+			cause = slog.Int("status", resp.StatusCode)
+		}
+		c.log(ctx, logger, slog.LevelWarn, "Retrying request",
+			slog.String("url", req.URL.String()),
+			cause,
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+		)
+		if err := sleepContext(ctx, delay); err != nil {
+			return req, nil, err
+		}
+		c.metrics.retries.Add(1)
+		req, resp, err = c.send(ctx, logger, next)
+	}
+	return req, resp, err
+}
+
+// sleepContext waits for d or until ctx is done, returning ctx's error in
+// the latter case.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,395 @@
+package appleapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := map[string]struct {
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		"absent":        {header: "", want: 0, wantOK: false},
+		"seconds":       {header: "120", want: 2 * time.Minute, wantOK: true},
+		"zero seconds":  {header: "0", want: 0, wantOK: true},
+		"negative":      {header: "-5", want: 0, wantOK: false},
+		"http date":     {header: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second, wantOK: true},
+		"past date":     {header: now.Add(-time.Hour).Format(http.TimeFormat), want: 0, wantOK: true},
+		"invalid value": {header: "soon", want: 0, wantOK: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+			got, ok := ParseRetryAfter(resp, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseRetryAfter(%q) = %v, %v; want %v, %v", tt.header, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClient_Do_Retry(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		switch len(bodies) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithRetry(3, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if diff := cmp.Diff([]string{"payload", "payload", "payload"}, bodies); diff != "" {
+		t.Errorf("request bodies mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_Do_RetryLimits(t *testing.T) {
+	tests := map[string]struct {
+		status   int
+		attempts int
+		wantHits int
+	}{
+		"gives up after max attempts": {status: http.StatusBadGateway, attempts: 2, wantHits: 2},
+		"not retryable status":        {status: http.StatusBadRequest, attempts: 3, wantHits: 1},
+		"retry disabled":              {status: http.StatusServiceUnavailable, attempts: 1, wantHits: 1},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var hits int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+				WithAllowPlaintext(),
+				WithRetry(tt.attempts, 0),
+			)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status || hits != tt.wantHits {
+				t.Errorf("status = %d, hits = %d; want %d, %d", resp.StatusCode, hits, tt.status, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestClient_Do_RetryContextCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+		WithAllowPlaintext(),
+		WithRetry(2, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := c.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClient_RetryDelay_MaxRetryDelay(t *testing.T) {
+	c, err := NewClient(DefaultHTTPClientInitializer(), "", &MockTokenProvider{token: "tok"},
+		WithRetry(200, 100*time.Millisecond),
+		WithMaxRetryDelay(5*time.Second),
+		WithBackoffStrategy(Exponential),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	noHeader := &http.Response{Header: http.Header{}}
+	prev := time.Duration(0)
+	for attempt := 1; attempt < 200; attempt++ {
+		d := c.retryDelay(attempt, noHeader)
+		if d <= 0 || d > 5*time.Second {
+			t.Fatalf("attempt %d: delay = %v, want within (0, 5s]", attempt, d)
+		}
+		if d < prev {
+			t.Fatalf("attempt %d: delay = %v decreased from %v", attempt, d, prev)
+		}
+		prev = d
+	}
+	if prev != 5*time.Second {
+		t.Errorf("final delay = %v, want the cap of 5s", prev)
+	}
+}
+
+func TestClient_RetryDelay_RetryAfterCap(t *testing.T) {
+	retryAfter := &http.Response{Header: http.Header{"Retry-After": []string{"60"}}}
+
+	tests := map[string]struct {
+		opts []Option
+		want time.Duration
+	}{
+		"honored above cap": {opts: []Option{WithMaxRetryDelay(5 * time.Second)}, want: time.Minute},
+		"capped":            {opts: []Option{WithMaxRetryDelay(5 * time.Second), WithCapRetryAfter()}, want: 5 * time.Second},
+		"no cap":            {opts: []Option{WithCapRetryAfter()}, want: time.Minute},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := append([]Option{WithRetry(3, time.Second)}, tt.opts...)
+			c, err := NewClient(DefaultHTTPClientInitializer(), "", &MockTokenProvider{token: "tok"}, opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+			if got := c.retryDelay(1, retryAfter); got != tt.want {
+				t.Errorf("retryDelay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_RetryDelay_Uncapped(t *testing.T) {
+	c, err := NewClient(DefaultHTTPClientInitializer(), "", &MockTokenProvider{token: "tok"},
+		WithRetry(100, time.Second),
+		WithBackoffStrategy(Exponential),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	noHeader := &http.Response{Header: http.Header{}}
+	if got := c.retryDelay(3, noHeader); got != 4*time.Second {
+		t.Errorf("retryDelay(3) = %v, want 4s", got)
+	}
+	// Large attempts saturate instead of overflowing to a negative delay.
+	if got := c.retryDelay(99, noHeader); got <= 0 {
+		t.Errorf("retryDelay(99) = %v, want a positive delay", got)
+	}
+}
+
+func TestClient_Do_RetryableStatusCodes(t *testing.T) {
+	tests := map[string]struct {
+		codes    []int
+		status   int
+		wantHits int
+	}{
+		"added 408":             {codes: []int{408, 503}, status: http.StatusRequestTimeout, wantHits: 3},
+		"listed 503":            {codes: []int{408, 503}, status: http.StatusServiceUnavailable, wantHits: 3},
+		"excluded 429":          {codes: []int{408, 503}, status: http.StatusTooManyRequests, wantHits: 1},
+		"invalid codes ignored": {codes: []int{200, 302, 600}, status: http.StatusOK, wantHits: 1},
+		"no codes":              {codes: nil, status: http.StatusServiceUnavailable, wantHits: 1},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var hits int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			c, err := NewClient(DefaultHTTPClientInitializer(), srv.URL, &MockTokenProvider{token: "tok"},
+				WithAllowPlaintext(),
+				WithRetry(3, 0),
+				WithRetryableStatusCodes(tt.codes...),
+			)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if hits != tt.wantHits {
+				t.Errorf("hits = %d, want %d", hits, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestBackoffStrategies(t *testing.T) {
+	const base, max = 100 * time.Millisecond, 10 * time.Second
+
+	tests := map[string]struct {
+		strategy BackoffStrategy
+		lower    func(attempt int) time.Duration
+		upper    func(attempt int) time.Duration
+	}{
+		"full jitter": {
+			strategy: ExponentialFullJitter,
+			lower:    func(int) time.Duration { return 0 },
+			upper:    func(attempt int) time.Duration { return Exponential(attempt, base, max) },
+		},
+		"decorrelated jitter": {
+			strategy: DecorrelatedJitter,
+			lower:    func(int) time.Duration { return base },
+			upper: func(attempt int) time.Duration {
+				return min(exponential(attempt+1, base, 3), max)
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			seen := map[time.Duration]bool{}
+			for attempt := 1; attempt <= 100; attempt++ {
+				for range 20 {
+					d := tt.strategy(attempt, base, max)
+					if d < tt.lower(attempt) || d > tt.upper(attempt) || d > max {
+						t.Fatalf("attempt %d: delay %v outside [%v, %v]", attempt, d, tt.lower(attempt), tt.upper(attempt))
+					}
+					seen[d] = true
+				}
+			}
+			if len(seen) < 100 {
+				t.Errorf("only %d distinct delays over 2000 draws, want jitter", len(seen))
+			}
+			if d := tt.strategy(200, base, 0); d < 0 {
+				t.Errorf("uncapped delay at attempt 200 = %v, want non-negative", d)
+			}
+		})
+	}
+}
+
+func TestClient_RetryDelay_DefaultFullJitter(t *testing.T) {
+	c, err := NewClient(DefaultHTTPClientInitializer(), "", &MockTokenProvider{token: "tok"},
+		WithRetry(10, time.Second),
+		WithMaxRetryDelay(3*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	noHeader := &http.Response{Header: http.Header{}}
+	seen := map[time.Duration]bool{}
+	for range 50 {
+		d := c.retryDelay(5, noHeader)
+		if d < 0 || d > 3*time.Second {
+			t.Fatalf("delay = %v, want within [0, 3s]", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("expected the default strategy to add jitter")
+	}
+}
+
+func TestClient_Do_ConnErrorRetry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		opts      []Option
+		wantErr   bool
+		wantDials int32
+	}{
+		"retried":        {opts: []Option{WithConnErrorRetry()}, wantDials: 2},
+		"without option": {opts: nil, wantErr: true, wantDials: 1},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var dials atomic.Int32
+			initializer := func() (*http.Client, error) {
+				tr := &http.Transport{
+					DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+						if dials.Add(1) == 1 {
+							return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+						}
+						return (&net.Dialer{}).DialContext(ctx, network, addr)
+					},
+				}
+				return &http.Client{Transport: tr}, nil
+			}
+			opts := append([]Option{WithAllowPlaintext(), WithRetry(3, time.Millisecond)}, tt.opts...)
+			c, err := NewClient(initializer, srv.URL, &MockTokenProvider{token: "tok"}, opts...)
+			if err != nil {
+				t.Fatalf("NewClient failed: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+			resp, err := c.Do(req)
+			if tt.wantErr {
+				if err == nil {
+					DrainBody(resp)
+					t.Fatal("expected the dial error to be returned")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Do failed: %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != "payload" {
+					t.Errorf("body = %q, want %q", body, "payload")
+				}
+			}
+			if got := dials.Load(); got != tt.wantDials {
+				t.Errorf("dialed %d times, want %d", got, tt.wantDials)
+			}
+		})
+	}
+}
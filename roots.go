@@ -0,0 +1,21 @@
+package appleapi
+
+import (
+	"crypto/x509"
+	_ "embed"
+)
+
+// appleRootsPEM holds the root certificates that Apple's API endpoints chain
+// to. See the header of apple_roots.pem for the update policy.
+//
+//go:embed apple_roots.pem
+var appleRootsPEM []byte
+
+// AppleRootCAs returns a new certificate pool containing the embedded root
+// certificates of Apple's API endpoints. It is independent of the system
+// trust store, which makes it usable in minimal containers without CA bundles.
+func AppleRootCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(appleRootsPEM)
+	return pool
+}
@@ -0,0 +1,52 @@
+package appleapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAppleRootCAs(t *testing.T) {
+	pool := AppleRootCAs()
+	if pool == nil {
+		t.Fatal("expected a non-nil pool")
+	}
+	if got := len(pool.Subjects()); got != 3 {
+		t.Errorf("pool has %d roots, want 3", got)
+	}
+}
+
+func TestWithAppleRootCAs(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	init := func() (*http.Client, error) {
+		cli := srv.Client()
+		tr := cli.Transport.(*http.Transport)
+		tr.TLSClientConfig.MinVersion = tls.VersionTLS12
+		return cli, nil
+	}
+	c, err := NewClient(init, srv.URL, &MockTokenProvider{token: "tok"}, WithAppleRootCAs())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	tlsCfg := c.BaseTransport().TLSClientConfig
+	if !tlsCfg.RootCAs.Equal(AppleRootCAs()) {
+		t.Fatal("expected the transport to use the Apple root pool")
+	}
+	if tlsCfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %x, want existing settings kept", tlsCfg.MinVersion)
+	}
+
+	// The test server's certificate is not signed by an Apple root.
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	_, err = c.Do(req)
+	var unknown x509.UnknownAuthorityError
+	if !errors.As(err, &unknown) {
+		t.Errorf("Do error = %v, want x509.UnknownAuthorityError", err)
+	}
+}
@@ -0,0 +1,46 @@
+package appleapi
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// acquireStream waits for a free slot of the limit set with
+// WithMaxConcurrentStreams. It returns a function that releases the slot,
+// which is safe to call more than once, or ctx's error if ctx is done first.
+func (c *Client) acquireStream(ctx context.Context) (func(), error) {
+	if c.streams == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.streams <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-c.streams }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// releaseBody is a response body that releases its stream slot once it has
+// been read to the end or closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Read implements io.Reader, releasing the slot at the end of the body.
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.release()
+	}
+	return n, err
+}
+
+// Close implements io.Closer, releasing the slot.
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -26,6 +26,34 @@ func (t *UnixTime) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalText implements the encoding.TextMarshaler interface for UnixTime.
+// It formats the time in UTC as RFC3339Nano, for encoders that use the text
+// form. JSON encoding is not affected and stays numeric.
+func (t UnixTime) MarshalText() ([]byte, error) {
+	return time.Time(t).UTC().AppendFormat(nil, time.RFC3339Nano), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface for UnixTime.
+// It parses an RFC3339 time, with or without fractional seconds.
+func (t *UnixTime) UnmarshalText(data []byte) error {
+	ut, err := ParseUnixTime(string(data))
+	if err != nil {
+		return err
+	}
+	*t = ut
+	return nil
+}
+
+// ParseUnixTime parses an RFC3339 string, with or without fractional
+// seconds, into a UnixTime in UTC.
+func ParseUnixTime(s string) (UnixTime, error) {
+	tm, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return UnixTime{}, err
+	}
+	return UnixTime(tm.UTC()), nil
+}
+
 // Time returns the UnixTime as a standard time.Time.
 func (t UnixTime) Time() time.Time {
 	return time.Time(t)
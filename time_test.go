@@ -97,3 +97,60 @@ func TestUnixTime_String(t *testing.T) {
 		})
 	}
 }
+
+func TestUnixTime_TextRoundTrip(t *testing.T) {
+	tests := map[string]struct {
+		in   time.Time
+		want string
+	}{
+		"millis":  {in: time.UnixMilli(1730812345678).UTC(), want: "2024-11-05T13:12:25.678Z"},
+		"nanos":   {in: time.Unix(1730812345, 123456789).UTC(), want: "2024-11-05T13:12:25.123456789Z"},
+		"seconds": {in: time.Unix(1730812345, 0).UTC(), want: "2024-11-05T13:12:25Z"},
+		"non-UTC": {in: time.Unix(1730812345, 0).In(time.FixedZone("JST", 9*3600)), want: "2024-11-05T13:12:25Z"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			text, err := appleapi.UnixTime(tt.in).MarshalText()
+			if err != nil {
+				t.Fatalf("MarshalText failed: %v", err)
+			}
+			if string(text) != tt.want {
+				t.Errorf("MarshalText = %s; want %s", text, tt.want)
+			}
+
+			var ut appleapi.UnixTime
+			if err := ut.UnmarshalText(text); err != nil {
+				t.Fatalf("UnmarshalText failed: %v", err)
+			}
+			if !ut.Time().Equal(tt.in) {
+				t.Errorf("round trip = %v; want %v", ut.Time(), tt.in)
+			}
+		})
+	}
+}
+
+func TestParseUnixTime(t *testing.T) {
+	ut, err := appleapi.ParseUnixTime("2024-11-05T22:12:25.678+09:00")
+	if err != nil {
+		t.Fatalf("ParseUnixTime failed: %v", err)
+	}
+	want := time.UnixMilli(1730812345678).UTC()
+	if got := ut.Time(); !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("ParseUnixTime = %v; want %v", got, want)
+	}
+
+	if _, err := appleapi.ParseUnixTime("1730812345678"); err == nil {
+		t.Error("expected an error for a non-RFC3339 string")
+	}
+}
+
+func TestUnixTime_JSONStaysNumeric(t *testing.T) {
+	data, err := json.Marshal(map[string]appleapi.UnixTime{"t": appleapi.UnixTime(time.UnixMilli(1730812345678))})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `{"t":1730812345678}`; string(data) != want {
+		t.Errorf("Marshal = %s; want %s", data, want)
+	}
+}
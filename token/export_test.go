@@ -0,0 +1,6 @@
+package token
+
+// SetIndent makes SignedString emit indented JSON segments, for tests only.
+func (jwt *JWTClaims) SetIndent(indent bool) {
+	jwt.indent = indent
+}
@@ -0,0 +1,47 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrKeyMismatch is returned by VerifyKeyID when a key's fingerprint differs
+// from the expected one.
+var ErrKeyMismatch = errors.New("key fingerprint mismatch")
+
+// KeyFingerprint returns the lowercase hex SHA-256 digest of the key's
+// public key in SPKI (PKIX) DER form, the same value reported by
+// "openssl pkey -pubout -outform DER | sha256sum". It returns an empty
+// string if key is nil or its public key cannot be encoded.
+func KeyFingerprint(key *ecdsa.PrivateKey) string {
+	if key == nil {
+		return ""
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyKeyID checks that key has the fingerprint expectedID, as returned by
+// KeyFingerprint, so that deploying the wrong .p8 file is caught at startup.
+// expectedID is compared case-insensitively and may separate bytes with
+// colons. A mismatch returns an error wrapping ErrKeyMismatch.
+func VerifyKeyID(key *ecdsa.PrivateKey, expectedID string) error {
+	got := KeyFingerprint(key)
+	if got == "" {
+		return errors.New("cannot compute fingerprint of key")
+	}
+	want := strings.ToLower(strings.ReplaceAll(expectedID, ":", ""))
+	if got != want {
+		return fmt.Errorf("%w: got %s, want %s", ErrKeyMismatch, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package token_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+func TestKeyFingerprint(t *testing.T) {
+	key1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	key2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+
+	fp := token.KeyFingerprint(key1)
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(fp) {
+		t.Fatalf("fingerprint %q is not a lowercase hex SHA-256 digest", fp)
+	}
+	if again := token.KeyFingerprint(key1); again != fp {
+		t.Errorf("fingerprint not stable: %q then %q", fp, again)
+	}
+	if other := token.KeyFingerprint(key2); other == fp {
+		t.Error("expected different keys to have different fingerprints")
+	}
+	if got := token.KeyFingerprint(nil); got != "" {
+		t.Errorf("KeyFingerprint(nil) = %q, want empty", got)
+	}
+}
+
+func TestVerifyKeyID(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	fp := token.KeyFingerprint(key)
+
+	var colons []string
+	for i := 0; i < len(fp); i += 2 {
+		colons = append(colons, fp[i:i+2])
+	}
+
+	tests := map[string]struct {
+		key      *ecdsa.PrivateKey
+		expected string
+		wantErr  error
+	}{
+		"match":           {key: key, expected: fp},
+		"uppercase":       {key: key, expected: strings.ToUpper(fp)},
+		"colon-separated": {key: key, expected: strings.Join(colons, ":")},
+		"wrong key":       {key: other, expected: fp, wantErr: token.ErrKeyMismatch},
+		"empty expected":  {key: key, expected: "", wantErr: token.ErrKeyMismatch},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := token.VerifyKeyID(tt.key, tt.expected)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyKeyID() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := token.VerifyKeyID(nil, fp); err == nil {
+		t.Error("expected an error for a nil key")
+	}
+}
@@ -5,7 +5,10 @@ package token
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"strconv"
 )
 
 // Header defines the JWT header fields.
@@ -16,25 +19,149 @@ type Header struct {
 
 // Payload defines the JWT payload (claims).
 type Payload struct {
-	Issuer   string `json:"iss,omitempty"` // Token issuer
-	IssuedAt int64  `json:"iat,omitempty"` // Issued at (Unix time)
+	Issuer    string         `json:"iss,omitempty"` // Token issuer
+	IssuedAt  int64          `json:"iat,omitempty"` // Issued at (Unix time)
+	ExpiresAt int64          `json:"exp,omitempty"` // Expiration time (Unix time)
+	Extra     map[string]any `json:"-"`             // Additional claims merged into the payload
+}
+
+// ErrMissingClaim is returned by Payload.Validate when a required claim is
+// missing or empty.
+var ErrMissingClaim = errors.New("missing required claim")
+
+// Validate returns an error wrapping ErrMissingClaim for the first of the
+// required claims that is missing or empty. The registered claims iss, iat
+// and exp are read from their fields; other names are looked up in Extra.
+func (p Payload) Validate(required ...string) error {
+	for _, name := range required {
+		var ok bool
+		switch name {
+		case "iss":
+			ok = p.Issuer != ""
+		case "iat":
+			ok = p.IssuedAt != 0
+		case "exp":
+			ok = p.ExpiresAt != 0
+		default:
+			v, found := p.Extra[name]
+			ok = found && v != nil && v != ""
+		}
+		if !ok {
+			return fmt.Errorf("%w %q", ErrMissingClaim, name)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface for Payload.
+// Extra claims are merged with the registered claims, which take precedence
+// on key collisions. Keys are emitted in sorted order, so identical claims
+// always marshal to identical bytes.
+func (p Payload) MarshalJSON() ([]byte, error) {
+	if len(p.Extra) == 0 {
+		type plain Payload // plain has no methods, avoiding recursion
+		return json.Marshal(plain(p))
+	}
+	claims := make(map[string]any, len(p.Extra)+2)
+	maps.Copy(claims, p.Extra)
+	if p.Issuer != "" {
+		claims["iss"] = p.Issuer
+	}
+	if p.IssuedAt != 0 {
+		claims["iat"] = p.IssuedAt
+	}
+	if p.ExpiresAt != 0 {
+		claims["exp"] = p.ExpiresAt
+	}
+	return json.Marshal(claims)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for Payload.
+// The iat and exp claims are accepted both as JSON numbers and as quoted
+// numbers, since some issuers encode them as strings. All other claims are
+// stored in Extra, which is nil if there are none.
+func (p *Payload) UnmarshalJSON(data []byte) error {
+	var claims struct {
+		Issuer    string      `json:"iss"`
+		IssuedAt  numericDate `json:"iat"`
+		ExpiresAt numericDate `json:"exp"`
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+	var extra map[string]any
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return err
+	}
+	delete(extra, "iss")
+	delete(extra, "iat")
+	delete(extra, "exp")
+	if len(extra) == 0 {
+		extra = nil
+	}
+	p.Issuer = claims.Issuer
+	p.IssuedAt = int64(claims.IssuedAt)
+	p.ExpiresAt = int64(claims.ExpiresAt)
+	p.Extra = extra
+	return nil
+}
+
+// numericDate is a JWT NumericDate that decodes from a JSON number or a
+// string holding a number. Fractional seconds are truncated.
+type numericDate int64
+
+// UnmarshalJSON implements the json.Unmarshaler interface for numericDate.
+func (d *numericDate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*d = numericDate(n)
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid numeric date %s", data)
+	}
+	*d = numericDate(f)
+	return nil
 }
 
 // JWTClaims represents a JWT containing a header and a payload.
 type JWTClaims struct {
 	Header  any
 	Payload any
+
+	// indent makes SignedString emit indented JSON in the header and payload
+	// segments, so decoded tokens are easier to read while debugging. It is
+	// internal so tokens always stay compact outside of this package.
+	indent bool
+}
+
+// marshalSegment marshals v for a token segment. encoding/json compacts the
+// output of nested json.Marshaler values (such as Payload with Extra claims
+// or json.RawMessage), so the result has no insignificant whitespace unless
+// indent is set.
+func marshalSegment(v any, indent bool) ([]byte, error) {
+	if indent {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
 }
 
 // SignedString creates a signed JWT string using the provided signer.
 //
 //	s: The Signer implementation used to sign the JWT.
 func (jwt *JWTClaims) SignedString(s Signer) (string, error) {
-	header, err := json.Marshal(jwt.Header)
+	header, err := marshalSegment(jwt.Header, jwt.indent)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal JWT header to JSON: %w", err)
 	}
-	payload, err := json.Marshal(jwt.Payload)
+	payload, err := marshalSegment(jwt.Payload, jwt.indent)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal JWT payload to JSON: %w", err)
 	}
@@ -104,3 +104,202 @@ func TestJWTToken_SignedString_SignerError(t *testing.T) {
 		t.Errorf("expected signer error, got %v", err)
 	}
 }
+
+func TestJWTToken_SignedString_ExtraClaimsDeterministic(t *testing.T) {
+	newClaims := func() *token.JWTClaims {
+		return &token.JWTClaims{
+			Header: token.Header{Alg: "ES256", Kid: "testkey"},
+			Payload: token.Payload{
+				Issuer:   "issuer",
+				IssuedAt: 1234567890,
+				Extra: map[string]any{
+					"scope": []string{"GET /v1/apps"},
+					"aud":   "appstoreconnect-v1",
+					"bid":   "com.example.app",
+					"nested": map[string]any{
+						"z": 1,
+						"a": 2,
+					},
+					"iss": "ignored", // registered claim wins
+				},
+			},
+		}
+	}
+	signer := &mockSigner{signData: []byte("signature")}
+
+	first, err := newClaims().SignedString(signer)
+	if err != nil {
+		t.Fatalf("SignedString returned error: %v", err)
+	}
+	for range 20 {
+		got, err := newClaims().SignedString(signer)
+		if err != nil {
+			t.Fatalf("SignedString returned error: %v", err)
+		}
+		if got != first {
+			t.Fatalf("token mismatch:\n got %s\nwant %s", got, first)
+		}
+	}
+
+	pb, err := base64.RawURLEncoding.DecodeString(strings.Split(first, ".")[1])
+	if err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	want := `{"aud":"appstoreconnect-v1","bid":"com.example.app","iat":1234567890,"iss":"issuer","nested":{"a":2,"z":1},"scope":["GET /v1/apps"]}`
+	if diff := cmp.Diff(want, string(pb)); diff != "" {
+		t.Errorf("payload mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPayload_UnmarshalJSON(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    token.Payload
+		wantErr bool
+	}{
+		"numeric iat": {
+			in:   `{"iss":"TEAMID","iat":123}`,
+			want: token.Payload{Issuer: "TEAMID", IssuedAt: 123},
+		},
+		"quoted iat": {
+			in:   `{"iss":"TEAMID","iat":"123"}`,
+			want: token.Payload{Issuer: "TEAMID", IssuedAt: 123},
+		},
+		"numeric and quoted exp": {
+			in:   `{"iat":123,"exp":"456"}`,
+			want: token.Payload{IssuedAt: 123, ExpiresAt: 456},
+		},
+		"fractional iat": {
+			in:   `{"iat":123.9}`,
+			want: token.Payload{IssuedAt: 123},
+		},
+		"null iat": {
+			in:   `{"iss":"TEAMID","iat":null}`,
+			want: token.Payload{Issuer: "TEAMID"},
+		},
+		"extra claims": {
+			in:   `{"iss":"TEAMID","iat":123,"aud":"appstoreconnect-v1","bid":"com.example.app"}`,
+			want: token.Payload{Issuer: "TEAMID", IssuedAt: 123, Extra: map[string]any{"aud": "appstoreconnect-v1", "bid": "com.example.app"}},
+		},
+		"invalid iat": {
+			in:      `{"iat":"yesterday"}`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got token.Payload
+			err := json.Unmarshal([]byte(tt.in), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("payload mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPayload_RoundTrip(t *testing.T) {
+	tests := map[string]token.Payload{
+		"registered claims": {Issuer: "TEAMID", IssuedAt: 123, ExpiresAt: 456},
+		"extra claims": {
+			Issuer:   "TEAMID",
+			IssuedAt: 123,
+			Extra: map[string]any{
+				"aud":    "appstoreconnect-v1",
+				"bid":    "com.example.app",
+				"scope":  []any{"GET /v1/apps"},
+				"nested": map[string]any{"n": float64(1)},
+			},
+		},
+	}
+
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(want)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var got token.Payload
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("payload mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPayload_MarshalJSON_Numeric(t *testing.T) {
+	tests := map[string]token.Payload{
+		`{"iss":"TEAMID","iat":123,"exp":456}`:           {Issuer: "TEAMID", IssuedAt: 123, ExpiresAt: 456},
+		`{"aud":"x","exp":456,"iat":123,"iss":"TEAMID"}`: {Issuer: "TEAMID", IssuedAt: 123, ExpiresAt: 456, Extra: map[string]any{"aud": "x"}},
+	}
+	for want, p := range tests {
+		got, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("Marshal = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestJWTToken_SignedString_Compact(t *testing.T) {
+	segments := func(t *testing.T, indent bool) []string {
+		t.Helper()
+		jwt := &token.JWTClaims{
+			Header: token.Header{Alg: "ES256", Kid: "KEYID"},
+			Payload: token.Payload{
+				Issuer:   "TEAMID",
+				IssuedAt: 1234567890,
+				Extra: map[string]any{
+					"aud":    "appstoreconnect-v1",
+					"nested": json.RawMessage("{ \"a\" : [ 1, 2 ],\n \"b\": \"x y\" }"),
+				},
+			},
+		}
+		jwt.SetIndent(indent)
+		signed, err := jwt.SignedString(&mockSigner{signData: []byte("signature")})
+		if err != nil {
+			t.Fatalf("SignedString returned error: %v", err)
+		}
+		parts := strings.Split(signed, ".")
+		var decoded []string
+		for _, part := range parts[:2] {
+			b, err := base64.RawURLEncoding.DecodeString(part)
+			if err != nil {
+				t.Fatalf("failed to decode segment: %v", err)
+			}
+			decoded = append(decoded, string(b))
+		}
+		return decoded
+	}
+
+	for i, seg := range segments(t, false) {
+		// Whitespace inside string values ("x y") is significant and kept.
+		if strings.ContainsAny(strings.ReplaceAll(seg, "x y", ""), " \t\n\r") {
+			t.Errorf("segment %d is not compact: %s", i, seg)
+		}
+	}
+	want := `{"aud":"appstoreconnect-v1","iat":1234567890,"iss":"TEAMID","nested":{"a":[1,2],"b":"x y"}}`
+	if got := segments(t, false)[1]; got != want {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+
+	for i, seg := range segments(t, true) {
+		if !strings.Contains(seg, "\n  ") {
+			t.Errorf("segment %d is not indented: %s", i, seg)
+		}
+	}
+}
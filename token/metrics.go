@@ -0,0 +1,98 @@
+package token
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Metrics summarizes the latency of recent signings.
+// It is the zero value when latency sampling is disabled or no token has
+// been signed yet.
+type Metrics struct {
+	Samples int           // Number of signings the percentiles are computed from
+	P50     time.Duration // Median signing latency
+	P95     time.Duration // 95th percentile signing latency
+	P99     time.Duration // 99th percentile signing latency
+}
+
+// CacheStats counts how GetToken calls were served.
+type CacheStats struct {
+	Hits   uint64 // Calls answered with the cached token
+	Misses uint64 // Calls that had to sign a new token
+}
+
+// WithLatencySampling records the latency of the last size signings so that
+// Metrics can report percentiles. Sampling is off by default; a size of zero
+// or less leaves it disabled.
+func WithLatencySampling(size int) Option {
+	return func(tp *TokenProvider) {
+		if size > 0 {
+			tp.latency = &latencyRing{samples: make([]time.Duration, size)}
+		}
+	}
+}
+
+// Metrics returns latency percentiles for the most recent signings recorded
+// with WithLatencySampling.
+func (p *TokenProvider) Metrics() Metrics {
+	if p.latency == nil {
+		return Metrics{}
+	}
+	return p.latency.metrics()
+}
+
+// CacheStats returns the number of GetToken calls served from the token
+// cache and the number that signed a new token since the provider was created.
+func (p *TokenProvider) CacheStats() CacheStats {
+	return CacheStats{Hits: p.cacheHits.Load(), Misses: p.cacheMisses.Load()}
+}
+
+// latencyRing keeps the most recent signing latencies in a fixed-size ring,
+// so recording does not allocate.
+type latencyRing struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int  // Index written by the next record
+	full    bool // Set once every slot holds a sample
+}
+
+// record adds a signing latency, overwriting the oldest once the ring is full.
+func (r *latencyRing) record(d time.Duration) {
+	r.mu.Lock()
+	r.samples[r.next] = d
+	r.next++
+	if r.next == len(r.samples) {
+		r.next = 0
+		r.full = true
+	}
+	r.mu.Unlock()
+}
+
+// metrics computes the percentiles of the recorded latencies.
+func (r *latencyRing) metrics() Metrics {
+	r.mu.Lock()
+	n := r.next
+	if r.full {
+		n = len(r.samples)
+	}
+	sorted := slices.Clone(r.samples[:n])
+	r.mu.Unlock()
+
+	if n == 0 {
+		return Metrics{}
+	}
+	slices.Sort(sorted)
+	return Metrics{
+		Samples: n,
+		P50:     percentile(sorted, 50),
+		P95:     percentile(sorted, 95),
+		P99:     percentile(sorted, 99),
+	}
+}
+
+// percentile returns the p-th percentile of sorted using the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	return sorted[max(rank, 1)-1]
+}
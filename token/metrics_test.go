@@ -0,0 +1,75 @@
+package token_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+func TestTokenProvider_Metrics(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	tp := token.NewProvider("ABC123DEFG", "TEAMID1234", priv, token.WithLatencySampling(100)).(*token.TokenProvider)
+
+	if got := tp.Metrics(); got != (token.Metrics{}) {
+		t.Errorf("Metrics() before signing = %+v, want zero", got)
+	}
+
+	now := time.Now()
+	for i := range 250 {
+		if _, _, err := tp.GenerateFor(now.Add(time.Duration(i) * time.Second)); err != nil {
+			t.Fatalf("GenerateFor failed: %v", err)
+		}
+	}
+
+	m := tp.Metrics()
+	if m.Samples != 100 {
+		t.Errorf("Samples = %d, want 100", m.Samples)
+	}
+	if m.P50 <= 0 || m.P50 > m.P95 || m.P95 > m.P99 {
+		t.Errorf("percentiles not ordered: p50=%v p95=%v p99=%v", m.P50, m.P95, m.P99)
+	}
+	if m.P99 > time.Second {
+		t.Errorf("p99 = %v, implausibly slow for an ES256 signature", m.P99)
+	}
+}
+
+func TestTokenProvider_Metrics_Disabled(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	tp := token.NewProvider("ABC123DEFG", "TEAMID1234", priv).(*token.TokenProvider)
+	if _, err := tp.GetToken(time.Now()); err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if got := tp.Metrics(); got != (token.Metrics{}) {
+		t.Errorf("Metrics() = %+v, want zero when sampling is disabled", got)
+	}
+}
+
+func TestTokenProvider_CacheStats(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	tp := token.NewProvider("ABC123DEFG", "TEAMID1234", priv, token.WithTTL(time.Minute)).(*token.TokenProvider)
+
+	now := time.Now()
+	for _, at := range []time.Time{now, now.Add(time.Second), now.Add(2 * time.Second), now.Add(2 * time.Minute)} {
+		if _, err := tp.GetToken(at); err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+	}
+
+	want := token.CacheStats{Hits: 2, Misses: 2}
+	if got := tp.CacheStats(); got != want {
+		t.Errorf("CacheStats() = %+v, want %+v", got, want)
+	}
+}
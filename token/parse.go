@@ -0,0 +1,47 @@
+package token
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotAJWS is returned when a token in JWE compact form (five segments)
+// is passed where a signed JWT (JWS, three segments) is expected.
+var ErrNotAJWS = errors.New("token is a JWE, not a JWS")
+
+// DecodeUnverified decodes the header and payload of a compact JWT without
+// verifying its signature. Claims other than iss, iat and exp are returned in
+// the payload's Extra. It is intended for inspection and debugging only.
+func DecodeUnverified(tok string) (Header, Payload, error) {
+	var header Header
+	var payload Payload
+
+	parts := strings.Split(tok, ".")
+	switch len(parts) {
+	case 3:
+	case 5:
+		return header, payload, ErrNotAJWS
+	default:
+		return header, payload, fmt.Errorf("malformed token: expected 3 segments, got %d", len(parts))
+	}
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return header, payload, fmt.Errorf("failed to decode JWT header: %w", err)
+	}
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return header, payload, fmt.Errorf("failed to decode JWT payload: %w", err)
+	}
+	return header, payload, nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment into v.
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
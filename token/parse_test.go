@@ -0,0 +1,73 @@
+package token_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+func TestDecodeUnverified(t *testing.T) {
+	jwt := &token.JWTClaims{
+		Header:  token.Header{Alg: "ES256", Kid: "KEYID"},
+		Payload: token.Payload{Issuer: "TEAMID", IssuedAt: 1234567890},
+	}
+	signed, err := jwt.SignedString(&mockSigner{signData: []byte("signature")})
+	if err != nil {
+		t.Fatalf("SignedString returned error: %v", err)
+	}
+
+	tests := map[string]struct {
+		tok         string
+		wantHeader  token.Header
+		wantPayload token.Payload
+		wantErr     error
+		errContains string
+	}{
+		"valid JWS": {
+			tok:         signed,
+			wantHeader:  token.Header{Alg: "ES256", Kid: "KEYID"},
+			wantPayload: token.Payload{Issuer: "TEAMID", IssuedAt: 1234567890},
+		},
+		"JWE compact form": {
+			tok:     "eyJhbGciOiJSU0EtT0FFUCIsImVuYyI6IkEyNTZHQ00ifQ.key.iv.ciphertext.tag",
+			wantErr: token.ErrNotAJWS,
+		},
+		"too few segments": {
+			tok:         "abc.def",
+			errContains: "expected 3 segments, got 2",
+		},
+		"invalid header encoding": {
+			tok:         "!!!." + strings.Split(signed, ".")[1] + ".sig",
+			errContains: "failed to decode JWT header",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			header, payload, err := token.DecodeUnverified(tt.tok)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.errContains != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("error = %v, want containing %q", err, tt.errContains)
+				}
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.wantHeader, header); diff != "" {
+				t.Errorf("header mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantPayload, payload); diff != "" {
+				t.Errorf("payload mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
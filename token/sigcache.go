@@ -0,0 +1,91 @@
+package token
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// maxSignatureCacheEntries bounds the number of signatures kept by a
+// signature cache. Expired entries are purged when the bound is reached.
+const maxSignatureCacheEntries = 64
+
+// WithSignatureCache reuses the signature of an identical header.payload
+// string signed within window, instead of signing it again. This helps
+// callers that reconstruct the same token repeatedly, for example with
+// GenerateFor and a fixed issue time. ECDSA signatures are not deterministic,
+// so a reused signature differs from a fresh one but is equally valid. The
+// cache is cleared whenever the signing key changes. A window of zero or
+// less disables the cache.
+func WithSignatureCache(window time.Duration) Option {
+	return func(tp *TokenProvider) {
+		if window > 0 {
+			tp.sigCache = &signatureCache{window: window, entries: make(map[string]signatureEntry)}
+		} else {
+			tp.sigCache = nil
+		}
+	}
+}
+
+// signatureCache maps signed base strings to their signatures.
+type signatureCache struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]signatureEntry
+}
+
+// signatureEntry is a cached signature and the time it was made.
+type signatureEntry struct {
+	sig      []byte
+	signedAt time.Time
+}
+
+// cachedSigner is a Signer that consults a signatureCache before signing.
+type cachedSigner struct {
+	cache  *signatureCache
+	signer Signer
+	now    time.Time
+}
+
+// Sign returns the cached signature of data if it was signed within the
+// cache window, and otherwise signs data and caches it.
+func (s *cachedSigner) Sign(data []byte) ([]byte, error) {
+	c := s.cache
+	c.mu.Lock()
+	e, ok := c.entries[string(data)]
+	c.mu.Unlock()
+	if ok && s.now.Sub(e.signedAt) < c.window {
+		return bytes.Clone(e.sig), nil
+	}
+
+	sig, err := s.signer.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxSignatureCacheEntries {
+		for k, e := range c.entries {
+			if s.now.Sub(e.signedAt) >= c.window {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxSignatureCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[string(data)] = signatureEntry{sig: bytes.Clone(sig), signedAt: s.now}
+	return sig, nil
+}
+
+// reset discards all cached signatures, for example after the signing key
+// changed. It is a no-op on a nil cache.
+func (c *signatureCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	clear(c.entries)
+	c.mu.Unlock()
+}
@@ -0,0 +1,99 @@
+package token_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+// countingSigner counts the calls to the wrapped signer.
+type countingSigner struct {
+	token.Signer
+	calls atomic.Int32
+}
+
+func (s *countingSigner) Sign(data []byte) ([]byte, error) {
+	s.calls.Add(1)
+	return s.Signer.Sign(data)
+}
+
+func TestTokenProvider_WithSignatureCache(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time { return now }
+	tp := token.NewProvider("ABC123DEFG", "TEAMID1234", nil,
+		token.WithSignatureCache(time.Minute),
+		token.WithClock(clock),
+	).(*token.TokenProvider)
+	signer := &countingSigner{Signer: &token.SignerECDSA{PrivateKey: priv, Hash: crypto.SHA256}}
+	tp.RotateKey(signer, "ABC123DEFG")
+
+	generate := func(iat time.Time) string {
+		t.Helper()
+		tok, _, err := tp.GenerateFor(iat)
+		if err != nil {
+			t.Fatalf("GenerateFor failed: %v", err)
+		}
+		return tok
+	}
+
+	iat := now.Truncate(time.Second)
+	first := generate(iat)
+	for range 5 {
+		if got := generate(iat); got != first {
+			t.Fatal("expected identical inputs to reuse the cached signature")
+		}
+	}
+	if got := signer.calls.Load(); got != 1 {
+		t.Errorf("signer called %d times for identical inputs, want 1", got)
+	}
+
+	generate(iat.Add(time.Second))
+	if got := signer.calls.Load(); got != 2 {
+		t.Errorf("signer called %d times after a new input, want 2", got)
+	}
+
+	now = now.Add(time.Minute)
+	if got := generate(iat); got == first {
+		t.Error("expected a fresh signature once the window has passed")
+	}
+	if got := signer.calls.Load(); got != 3 {
+		t.Errorf("signer called %d times after the window, want 3", got)
+	}
+
+	other := &countingSigner{Signer: &token.SignerECDSA{PrivateKey: priv, Hash: crypto.SHA256}}
+	tp.RotateKey(other, "ABC123DEFG")
+	generate(iat)
+	if got := other.calls.Load(); got != 1 {
+		t.Errorf("new signer called %d times after rotation, want 1", got)
+	}
+}
+
+func TestTokenProvider_WithoutSignatureCache(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	tp := token.NewProvider("ABC123DEFG", "TEAMID1234", nil).(*token.TokenProvider)
+	signer := &countingSigner{Signer: &token.SignerECDSA{PrivateKey: priv, Hash: crypto.SHA256}}
+	tp.RotateKey(signer, "ABC123DEFG")
+
+	iat := time.Now().Truncate(time.Second)
+	for range 3 {
+		if _, _, err := tp.GenerateFor(iat); err != nil {
+			t.Fatalf("GenerateFor failed: %v", err)
+		}
+	}
+	if got := signer.calls.Load(); got != 3 {
+		t.Errorf("signer called %d times, want 3 without a cache", got)
+	}
+}
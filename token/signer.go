@@ -5,24 +5,79 @@ package token
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 )
 
-var _ Signer = &SignerECDSA{}
+var (
+	_ Signer = &SignerECDSA{}
+	_ Signer = &SignerRSA{}
+	_ Signer = &SignerEd25519{}
+)
+
+// SupportedAlgorithms returns the JWS algorithms accepted by SignerFor.
+func SupportedAlgorithms() []string {
+	return []string{"ES256", "RS256", "EdDSA"}
+}
+
+// SupportedCurves returns the elliptic curves accepted by SignerECDSA, named
+// as in the "crv" parameter of a JWK.
+func SupportedCurves() []string {
+	return []string{"P-256"}
+}
+
+// SignerFor returns a Signer for the JWS algorithm alg ("ES256", "RS256" or
+// "EdDSA") using key, which must be of the matching type: *ecdsa.PrivateKey,
+// *rsa.PrivateKey or ed25519.PrivateKey respectively.
+func SignerFor(alg string, key crypto.PrivateKey) (Signer, error) {
+	switch alg {
+	case "ES256":
+		if k, ok := key.(*ecdsa.PrivateKey); ok && k != nil {
+			return &SignerECDSA{PrivateKey: k, Hash: crypto.SHA256}, nil
+		}
+	case "RS256":
+		if k, ok := key.(*rsa.PrivateKey); ok && k != nil {
+			return &SignerRSA{PrivateKey: k, Hash: crypto.SHA256}, nil
+		}
+	case "EdDSA":
+		if k, ok := key.(ed25519.PrivateKey); ok && len(k) == ed25519.PrivateKeySize {
+			return &SignerEd25519{PrivateKey: k}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	return nil, fmt.Errorf("key of type %T cannot be used with algorithm %q", key, alg)
+}
 
 // Signer defines the interface for signing strings.
+//
+// A Signer may also implement Alg() string to name the JWS algorithm it
+// signs with; the provider writes it to the "alg" header of its tokens. The
+// built-in signers do. Signers without it are assumed to use ES256.
 type Signer interface {
 	Sign(data []byte) ([]byte, error)
 }
 
+// signerAlg returns the JWS algorithm of s, or ES256 if s does not name one.
+func signerAlg(s Signer) string {
+	if a, ok := s.(interface{ Alg() string }); ok && a.Alg() != "" {
+		return a.Alg()
+	}
+	return "ES256"
+}
+
 // SignerECDSA implements the Signer interface using ECDSA.
 type SignerECDSA struct {
 	PrivateKey *ecdsa.PrivateKey // ECDSA private key
 	Hash       crypto.Hash       // Hash algorithm used for signing
 }
 
+// Alg returns "ES256".
+func (se *SignerECDSA) Alg() string { return "ES256" }
+
 // Sign generates an ECDSA signature for the given string.
 // It supports only 256-bit curves (P-256).
 func (se *SignerECDSA) Sign(data []byte) ([]byte, error) {
@@ -56,3 +111,47 @@ func (se *SignerECDSA) Sign(data []byte) ([]byte, error) {
 
 	return signature, nil
 }
+
+// SignerRSA implements the Signer interface using RSASSA-PKCS1-v1_5 (RS256).
+type SignerRSA struct {
+	PrivateKey *rsa.PrivateKey // RSA private key
+	Hash       crypto.Hash     // Hash algorithm used for signing
+}
+
+// Alg returns "RS256".
+func (sr *SignerRSA) Alg() string { return "RS256" }
+
+// Sign generates an RSASSA-PKCS1-v1_5 signature for the given string.
+func (sr *SignerRSA) Sign(data []byte) ([]byte, error) {
+	if sr.PrivateKey == nil {
+		return nil, errors.New("missing private key")
+	}
+	if !sr.Hash.Available() {
+		sr.Hash = crypto.SHA256
+	}
+
+	h := sr.Hash.New()
+	h.Write(data)
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, sr.PrivateKey, sr.Hash, h.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("rsa sign failed: %w", err)
+	}
+	return signature, nil
+}
+
+// SignerEd25519 implements the Signer interface using Ed25519 (EdDSA).
+type SignerEd25519 struct {
+	PrivateKey ed25519.PrivateKey // Ed25519 private key
+}
+
+// Alg returns "EdDSA".
+func (se *SignerEd25519) Alg() string { return "EdDSA" }
+
+// Sign generates an Ed25519 signature for the given string.
+func (se *SignerEd25519) Sign(data []byte) ([]byte, error) {
+	if len(se.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("missing private key")
+	}
+	return ed25519.Sign(se.PrivateKey, data), nil
+}
@@ -3,10 +3,13 @@ package token_test
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	"math/big"
+	"slices"
 	"testing"
 
 	"github.com/takimoto3/appleapi-core/token"
@@ -58,3 +61,153 @@ func TestSignerECDSA_UnsupportedCurve(t *testing.T) {
 		t.Fatal("expected error for unsupported curve, got nil")
 	}
 }
+
+func TestSignerFor(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	message := []byte("test message")
+	digest := sha256.Sum256(message)
+
+	tests := map[string]struct {
+		alg    string
+		key    crypto.PrivateKey
+		verify func(sig []byte) bool
+	}{
+		"ES256": {
+			alg: "ES256",
+			key: ecKey,
+			verify: func(sig []byte) bool {
+				r := new(big.Int).SetBytes(sig[:32])
+				s := new(big.Int).SetBytes(sig[32:])
+				return ecdsa.Verify(&ecKey.PublicKey, digest[:], r, s)
+			},
+		},
+		"RS256": {
+			alg: "RS256",
+			key: rsaKey,
+			verify: func(sig []byte) bool {
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig) == nil
+			},
+		},
+		"EdDSA": {
+			alg: "EdDSA",
+			key: edKey,
+			verify: func(sig []byte) bool {
+				return ed25519.Verify(edKey.Public().(ed25519.PublicKey), message, sig)
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			signer, err := token.SignerFor(tt.alg, tt.key)
+			if err != nil {
+				t.Fatalf("SignerFor failed: %v", err)
+			}
+			sig, err := signer.Sign(message)
+			if err != nil {
+				t.Fatalf("Sign returned error: %v", err)
+			}
+			if !tt.verify(sig) {
+				t.Error("signature verification failed")
+			}
+		})
+	}
+}
+
+func TestSignerFor_Errors(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+
+	tests := map[string]struct {
+		alg     string
+		key     crypto.PrivateKey
+		wantErr string
+	}{
+		"key mismatch": {
+			alg:     "RS256",
+			key:     ecKey,
+			wantErr: `key of type *ecdsa.PrivateKey cannot be used with algorithm "RS256"`,
+		},
+		"nil key": {
+			alg:     "ES256",
+			key:     nil,
+			wantErr: `key of type <nil> cannot be used with algorithm "ES256"`,
+		},
+		"unsupported algorithm": {
+			alg:     "HS256",
+			key:     ecKey,
+			wantErr: `unsupported algorithm "HS256"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			signer, err := token.SignerFor(tt.alg, tt.key)
+			if err == nil || signer != nil {
+				t.Fatalf("SignerFor = %v, %v; want an error", signer, err)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("error = %q, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSupportedAlgorithms(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+	keys := map[string]crypto.PrivateKey{"ES256": ecKey, "RS256": rsaKey, "EdDSA": edKey}
+
+	algs := token.SupportedAlgorithms()
+	for _, want := range []string{"ES256", "RS256", "EdDSA"} {
+		if !slices.Contains(algs, want) {
+			t.Errorf("SupportedAlgorithms() = %v, missing %q", algs, want)
+		}
+	}
+	// Every listed algorithm must be accepted by SignerFor.
+	for _, alg := range algs {
+		if _, err := token.SignerFor(alg, keys[alg]); err != nil {
+			t.Errorf("SignerFor(%q) failed: %v", alg, err)
+		}
+	}
+
+	curves := token.SupportedCurves()
+	if !slices.Contains(curves, "P-256") {
+		t.Errorf("SupportedCurves() = %v, missing %q", curves, "P-256")
+	}
+	byName := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+	for name, curve := range byName {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate %s key: %v", name, err)
+		}
+		_, err = (&token.SignerECDSA{PrivateKey: key, Hash: crypto.SHA256}).Sign([]byte("data"))
+		if supported := slices.Contains(curves, name); supported != (err == nil) {
+			t.Errorf("curve %s: listed %v, but Sign error = %v", name, supported, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSignerTimeout is returned when a signer does not finish within the
+// duration set with WithSignerTimeout.
+var ErrSignerTimeout = errors.New("signer timed out")
+
+// WithSignerTimeout bounds each Sign call of a custom signer, such as one
+// backed by a remote KMS, to d. A call that takes longer fails with an error
+// wrapping ErrSignerTimeout; the signer keeps running in the background and
+// its result is discarded. The in-memory signers of this package are not
+// wrapped, since they do not block. A duration of zero or less disables the
+// timeout.
+func WithSignerTimeout(d time.Duration) Option {
+	return func(tp *TokenProvider) {
+		tp.signTimeout = max(d, 0)
+	}
+}
+
+// timeoutSigner bounds the duration of each Sign call of the wrapped signer.
+type timeoutSigner struct {
+	signer  Signer
+	timeout time.Duration
+}
+
+// withTimeout wraps signer in a timeoutSigner unless d is not positive or
+// signer is one of the in-memory signers of this package.
+func withTimeout(signer Signer, d time.Duration) Signer {
+	switch signer.(type) {
+	case *SignerECDSA, *SignerRSA, *SignerEd25519:
+		return signer
+	}
+	if d <= 0 {
+		return signer
+	}
+	return &timeoutSigner{signer: signer, timeout: d}
+}
+
+// signResult is the outcome of a Sign call run in the background.
+type signResult struct {
+	sig []byte
+	err error
+}
+
+// Sign implements Signer.
+func (s *timeoutSigner) Sign(data []byte) ([]byte, error) {
+	done := make(chan signResult, 1)
+	go func() {
+		sig, err := s.signer.Sign(data)
+		done <- signResult{sig, err}
+	}()
+
+	t := time.NewTimer(s.timeout)
+	defer t.Stop()
+	select {
+	case r := <-done:
+		return r.sig, r.err
+	case <-t.C:
+		return nil, fmt.Errorf("%w after %v", ErrSignerTimeout, s.timeout)
+	}
+}
@@ -0,0 +1,54 @@
+package token_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+func TestTokenProvider_WithSignerTimeout(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	base := &token.SignerECDSA{PrivateKey: priv, Hash: crypto.SHA256}
+
+	tests := map[string]struct {
+		signer  token.Signer
+		timeout time.Duration
+		wantErr error
+	}{
+		"slow signer times out":     {signer: &slowSigner{Signer: base, delay: time.Second}, timeout: 20 * time.Millisecond, wantErr: token.ErrSignerTimeout},
+		"fast signer within budget": {signer: &slowSigner{Signer: base}, timeout: time.Second},
+		"slow signer without limit": {signer: &slowSigner{Signer: base, delay: 20 * time.Millisecond}},
+		"in-memory signer":          {signer: base, timeout: time.Nanosecond},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tp := token.NewProvider("ABC123DEFG", "TEAMID1234", nil, token.WithSignerTimeout(tt.timeout)).(*token.TokenProvider)
+			tp.RotateKey(tt.signer, "ABC123DEFG")
+
+			start := time.Now()
+			tok, err := tp.GetToken(start)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetToken error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+					t.Errorf("GetToken took %v, want it to return at the timeout", elapsed)
+				}
+				return
+			}
+			if tok == "" {
+				t.Error("expected a token")
+			}
+		})
+	}
+}
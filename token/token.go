@@ -3,6 +3,7 @@ package token
 // Package token provides utilities for generating and caching JWTs for Apple APIs.
 
 import (
+	"context"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/x509"
@@ -10,18 +11,32 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-var _ Provider = &TokenProvider{}
+var (
+	_ Provider = &TokenProvider{}
+	_ Provider = ProviderFunc(nil)
+	_ Provider = staticProvider("")
+)
 
 // TokenTTL is the default time-to-live for a cached token.
 // After this duration, the token is considered expired and should be refreshed.
 const TokenTTL = 30 * time.Minute
 
+// MaxTokenTTL is the default upper bound for the token time-to-live.
+// APNs rejects provider tokens older than one hour, even if they claim a
+// longer validity, so longer TTLs are clamped to this value.
+const MaxTokenTTL = time.Hour
+
 // Option represents a functional option for TokenProvider configuration.
 type Option func(*TokenProvider)
 
@@ -33,14 +48,152 @@ func WithLogger(l *slog.Logger) Option {
 	}
 }
 
+// WithMaxTTL sets the upper bound applied to the token TTL, replacing the
+// default MaxTokenTTL. A TTL above the bound is clamped with a warning.
+// Pass zero to disable the bound for non-APNs services that accept
+// longer-lived tokens.
+func WithMaxTTL(max time.Duration) Option {
+	return func(tp *TokenProvider) {
+		tp.maxTTL = max
+	}
+}
+
+// WithLogLevel sets the minimum level for records emitted by the provider.
+// Records below the level are dropped before reaching the logger's handler.
+// Pass a *slog.LevelVar to adjust the threshold at runtime.
+func WithLogLevel(level slog.Leveler) Option {
+	return func(tp *TokenProvider) {
+		tp.logLevel = level
+	}
+}
+
 // WithTTL sets a custom time-to-live for the generated tokens.
-// This overrides the default TokenTTL constant.
+// This overrides the default TokenTTL constant. The TTL is clamped to
+// MaxTokenTTL unless a different bound is set with WithMaxTTL.
 func WithTTL(ttl time.Duration) Option {
 	return func(tp *TokenProvider) {
 		tp.tokenTTL = ttl
 	}
 }
 
+// WithExtraClaims adds claims to every generated token's payload, such as
+// "aud" or "bid" required by some Apple services. Registered claims set by
+// the provider (iss, iat) take precedence over keys in claims.
+func WithExtraClaims(claims map[string]any) Option {
+	return func(tp *TokenProvider) {
+		if tp.extraClaims == nil {
+			tp.extraClaims = make(map[string]any, len(claims))
+		}
+		maps.Copy(tp.extraClaims, claims)
+	}
+}
+
+// WithDevelopmentClaims sets claims that are merged over the extra claims
+// while the provider is in development mode, for services whose tokens differ
+// between sandbox and production. Use SetDevelopment to switch modes.
+func WithDevelopmentClaims(claims map[string]any) Option {
+	return func(tp *TokenProvider) {
+		if tp.devClaims == nil {
+			tp.devClaims = make(map[string]any, len(claims))
+		}
+		maps.Copy(tp.devClaims, claims)
+	}
+}
+
+// WithStringClaim adds a string claim, such as "bid", to every generated
+// token's payload. It is a typed shorthand for WithExtraClaims.
+func WithStringClaim(name, value string) Option {
+	return WithExtraClaims(map[string]any{name: value})
+}
+
+// WithInt64Claim adds an integer claim to every generated token's payload.
+// It is a typed shorthand for WithExtraClaims.
+func WithInt64Claim(name string, value int64) Option {
+	return WithExtraClaims(map[string]any{name: value})
+}
+
+// WithClock sets the time source the provider uses when it needs the
+// current time on its own, such as for a background refresh or the key
+// source reload schedule. The time passed to GetToken is still used for cache
+// checks and on-demand signing. The default is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(tp *TokenProvider) {
+		if now != nil {
+			tp.now = now
+		}
+	}
+}
+
+// WithRequiredClaims sets the claims that must be present and non-empty
+// before a token is signed, replacing the default of "iss". GetToken returns
+// an error wrapping ErrMissingClaim instead of a token Apple would reject.
+// Calling it with no names disables the check.
+func WithRequiredClaims(names ...string) Option {
+	return func(tp *TokenProvider) {
+		tp.requiredClaims = slices.Clone(names)
+	}
+}
+
+// WithKeyIDHeader emits the key ID under the given JWT header field instead
+// of the standard "kid", for verifiers that expect a different name. An
+// empty name, or "alg", is ignored.
+func WithKeyIDHeader(name string) Option {
+	return func(tp *TokenProvider) {
+		if name != "" && name != "alg" {
+			tp.kidHeader = name
+		}
+	}
+}
+
+// WithKeySource sets a function that supplies the signer and key ID, for
+// example from a KMS-backed key that rotates on a schedule. The source is
+// first called on the initial GetToken and then again once interval has
+// elapsed on the provider's clock (see WithClock), whatever time is passed to
+// GetToken; an interval of zero or less loads the key only once. When the
+// key ID changes, the cached token is discarded so the next token is signed
+// with the new key, under the signer's algorithm (see Signer). The key passed
+// to NewProvider may be nil when a key source is set.
+func WithKeySource(source func() (Signer, string, error), interval time.Duration) Option {
+	return func(tp *TokenProvider) {
+		tp.keySource = source
+		tp.keyInterval = interval
+	}
+}
+
+// WithBackgroundRefresh makes the provider regenerate the token in the
+// background once a cached token is within window of expiring, so callers
+// keep receiving the cached token instead of waiting for a new signature.
+// Use Flush to wait for an in-progress refresh.
+func WithBackgroundRefresh(window time.Duration) Option {
+	return func(tp *TokenProvider) {
+		tp.refreshWindow = window
+	}
+}
+
+// WithIssuedAtRounding rounds the iat claim of every token down to a multiple
+// of d, e.g. time.Minute. Providers that share a key and round to the same
+// boundary then sign identical tokens within a window, which helps caches
+// across a fleet. The token's expiry is measured from the rounded iat, so d
+// should be well below the TTL. A d of zero or less disables rounding.
+func WithIssuedAtRounding(d time.Duration) Option {
+	return func(tp *TokenProvider) {
+		tp.iatRounding = d
+	}
+}
+
+// WithFallbackSigner sets a secondary signer that is used only when the
+// primary signer fails, for example while a key is being rotated or a remote
+// signer is unavailable. Tokens signed by it carry keyID and alg in their
+// header and are cached like any other token. A nil signer or an empty keyID
+// or alg is ignored.
+func WithFallbackSigner(s Signer, keyID, alg string) Option {
+	return func(tp *TokenProvider) {
+		if s != nil && keyID != "" && alg != "" {
+			tp.fallback = &fallbackSigner{signer: s, keyID: keyID, alg: alg}
+		}
+	}
+}
+
 // Provider defines the interface for obtaining JWT-based authentication tokens.
 type Provider interface {
 	// GetToken returns a cached token if still valid, or generates a new one.
@@ -50,6 +203,37 @@ type Provider interface {
 	GetToken(now time.Time) (string, error)
 }
 
+// ProviderFunc is an adapter that allows an ordinary function to be used as a Provider.
+type ProviderFunc func(now time.Time) (string, error)
+
+// GetToken calls f(now).
+func (f ProviderFunc) GetToken(now time.Time) (string, error) {
+	return f(now)
+}
+
+// NewStaticProvider returns a Provider whose GetToken always returns tok.
+// It is intended for tests against mock servers that do not verify JWTs;
+// never use it against Apple's services.
+func NewStaticProvider(tok string) Provider {
+	return staticProvider(tok)
+}
+
+// staticProvider is a Provider that returns a fixed token.
+type staticProvider string
+
+// GetToken returns the fixed token.
+func (p staticProvider) GetToken(time.Time) (string, error) {
+	return string(p), nil
+}
+
+// fallbackSigner is the signer set by WithFallbackSigner with the header
+// values of the tokens it signs.
+type fallbackSigner struct {
+	signer Signer
+	keyID  string
+	alg    string
+}
+
 type cachedToken struct {
 	Token    string
 	ExpireAt time.Time
@@ -61,10 +245,38 @@ type TokenProvider struct {
 	cache     atomic.Value
 	writeLock sync.Mutex
 	tokenTTL  time.Duration // tokenTTL is the duration before a cached token expires.
+	maxTTL    time.Duration // maxTTL caps tokenTTL; zero disables the cap.
 	logger    *slog.Logger  // logger for structured output, can be overridden.
+	logLevel  slog.Leveler  // logLevel is the minimum level to log; nil logs everything.
 	signer    Signer        // signer is used to sign JWT tokens.
 	keyID     string        // keyID is the Apple Key ID (or service-specific key identifier).
 	teamID    string        // teamID is the Apple Team ID (or issuer identifier).
+
+	extraClaims map[string]any // extraClaims are merged into every token's payload.
+	kidHeader   string         // kidHeader is the header field carrying the key ID; empty means "kid".
+	devClaims   map[string]any // devClaims are merged over extraClaims in development mode.
+	development bool           // development selects the development claims; guarded by writeLock.
+
+	requiredClaims []string         // requiredClaims must be non-empty before signing.
+	now            func() time.Time // now is the provider's time source for internal operations.
+
+	keySource   func() (Signer, string, error) // keySource supplies the signer and key ID, if set.
+	keyInterval time.Duration                  // keyInterval is the period between key source reloads.
+	keyReloadAt atomic.Int64                   // keyReloadAt is the Unix time (ns) of the next key reload.
+
+	refreshWindow time.Duration // refreshWindow is how long before expiry a background refresh starts.
+	refreshMu     sync.Mutex    // refreshMu guards refreshDone.
+	refreshDone   chan struct{} // refreshDone is closed when the running background refresh ends.
+
+	latency     *latencyRing    // latency holds recent signing latencies; nil disables sampling.
+	sigCache    *signatureCache // sigCache reuses signatures of identical inputs; nil disables it.
+	signTimeout time.Duration   // signTimeout bounds each Sign call of a custom signer; zero disables it.
+	selfVerify  bool            // selfVerify checks each signed token against the signer's public key.
+	iatRounding time.Duration   // iatRounding rounds iat down to a multiple of it; zero disables it.
+	fallback    *fallbackSigner // fallback signs tokens when the primary signer fails; nil disables it.
+
+	cacheHits   atomic.Uint64 // cacheHits counts GetToken calls served from the cache.
+	cacheMisses atomic.Uint64 // cacheMisses counts GetToken calls that signed a new token.
 }
 
 // NewProvider creates a new TokenProvider.
@@ -76,12 +288,20 @@ func NewProvider(keyID, teamID string, privkey *ecdsa.PrivateKey, opts ...Option
 		keyID:    keyID,
 		teamID:   teamID,
 		tokenTTL: TokenTTL,
+		maxTTL:   MaxTokenTTL,
+
+		requiredClaims: []string{"iss"},
+		now:            time.Now,
 	}
 	tp.cache.Store(cachedToken{})
 
 	for _, opt := range opts {
 		opt(tp)
 	}
+	if tp.maxTTL > 0 && tp.tokenTTL > tp.maxTTL {
+		tp.log(slog.LevelWarn, "Token TTL exceeds the maximum, clamping", "ttl", tp.tokenTTL, "max_ttl", tp.maxTTL)
+		tp.tokenTTL = tp.maxTTL
+	}
 
 	return tp
 }
@@ -89,8 +309,18 @@ func NewProvider(keyID, teamID string, privkey *ecdsa.PrivateKey, opts ...Option
 // GetToken returns a valid JWT token.
 // It reuses the cached token if still valid, or generates a new one.
 func (p *TokenProvider) GetToken(now time.Time) (string, error) {
+	if p.keySource != nil && p.now().UnixNano() >= p.keyReloadAt.Load() {
+		if err := p.reloadKey(); err != nil {
+			return "", err
+		}
+	}
+
 	c := p.cache.Load().(cachedToken)
 	if now.Before(c.ExpireAt) && c.Token != "" {
+		p.cacheHits.Add(1)
+		if p.refreshWindow > 0 && c.ExpireAt.Sub(now) <= p.refreshWindow {
+			p.startRefresh(c.ExpireAt)
+		}
 		return c.Token, nil
 	}
 	p.writeLock.Lock()
@@ -98,30 +328,291 @@ func (p *TokenProvider) GetToken(now time.Time) (string, error) {
 
 	c = p.cache.Load().(cachedToken)
 	if now.Before(c.ExpireAt) && c.Token != "" {
+		p.cacheHits.Add(1)
 		return c.Token, nil
 	}
 
-	jwt := JWTClaims{
-		Header:  Header{Alg: "ES256", Kid: p.keyID},
-		Payload: Payload{Issuer: p.teamID, IssuedAt: now.Unix()},
-	}
+	p.cacheMisses.Add(1)
+	return p.generate(now)
+}
 
-	newToken, err := jwt.SignedString(p.signer)
+// generate signs a new token issued at now and stores it in the cache.
+// The caller must hold writeLock.
+func (p *TokenProvider) generate(now time.Time) (string, error) {
+	iat := p.issuedAt(now)
+	newToken, err := p.sign(iat)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign JWT token: %w", err)
+		return "", err
 	}
-	expiresAt := now.Add(p.tokenTTL)
+	expiresAt := iat.Add(p.tokenTTL)
 
 	p.cache.Store(cachedToken{
 		Token:    newToken,
 		ExpireAt: expiresAt,
 	})
 
-	p.logger.Info("Token generated successfully", "expires_at", expiresAt)
+	p.log(slog.LevelInfo, "Token generated successfully", "expires_at", expiresAt)
 
 	return newToken, nil
 }
 
+// issuedAt returns the iat of a token signed at t, rounded down as set by
+// WithIssuedAtRounding.
+func (p *TokenProvider) issuedAt(t time.Time) time.Time {
+	if p.iatRounding > 0 {
+		return t.Truncate(p.iatRounding)
+	}
+	return t
+}
+
+// sign creates a signed token issued at iat without touching the cache.
+// The caller must hold writeLock.
+func (p *TokenProvider) sign(iat time.Time) (string, error) {
+	extra := p.extraClaims
+	if p.development && len(p.devClaims) > 0 {
+		extra = maps.Clone(extra)
+		if extra == nil {
+			extra = make(map[string]any, len(p.devClaims))
+		}
+		maps.Copy(extra, p.devClaims)
+	}
+	payload := Payload{Issuer: p.teamID, IssuedAt: iat.Unix(), Extra: extra}
+	if err := payload.Validate(p.requiredClaims...); err != nil {
+		return "", err
+	}
+
+	tok, err := p.signWith(p.signer, p.keyID, signerAlg(p.signer), payload)
+	if err != nil && p.fallback != nil {
+		p.log(slog.LevelWarn, "Primary signer failed, signing with the fallback key", "kid", p.fallback.keyID, "err", err)
+		tok, err = p.signWith(p.fallback.signer, p.fallback.keyID, p.fallback.alg, payload)
+	}
+	return tok, err
+}
+
+// signWith signs payload with signer, naming keyID and alg in the header.
+// The caller must hold writeLock.
+func (p *TokenProvider) signWith(signer Signer, keyID, alg string, payload Payload) (string, error) {
+	var header any = Header{Alg: alg, Kid: keyID}
+	if p.kidHeader != "" {
+		header = map[string]string{"alg": alg, p.kidHeader: keyID}
+	}
+	jwt := JWTClaims{
+		Header:  header,
+		Payload: payload,
+	}
+
+	s := withTimeout(signer, p.signTimeout)
+	if p.sigCache != nil {
+		s = &cachedSigner{cache: p.sigCache, signer: s, now: p.now()}
+	}
+	start := time.Now()
+	tok, err := jwt.SignedString(s)
+	if p.latency != nil {
+		p.latency.record(time.Since(start))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT token: %w", err)
+	}
+	if p.selfVerify {
+		if err := selfVerify(tok, signer); err != nil {
+			return "", err
+		}
+	}
+	return tok, nil
+}
+
+// GenerateFor signs a fresh token issued at iat, for example to mint a token
+// for a future time window in a batch job. The shared cache is neither read
+// nor updated. It returns the token and the time it expires, iat plus the TTL,
+// where iat is rounded as set by WithIssuedAtRounding.
+func (p *TokenProvider) GenerateFor(iat time.Time) (string, time.Time, error) {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	iat = p.issuedAt(iat)
+	tok, err := p.sign(iat)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tok, iat.Add(p.tokenTTL), nil
+}
+
+// CachedToken returns the cached token and its expiry without generating a
+// new one. ok is false if no token has been cached yet, for example before
+// the first GetToken or after RotateKey. The token may already be expired.
+func (p *TokenProvider) CachedToken() (tok string, expiresAt time.Time, ok bool) {
+	c := p.cache.Load().(cachedToken)
+	if c.Token == "" {
+		return "", time.Time{}, false
+	}
+	return c.Token, c.ExpireAt, true
+}
+
+// WriteCurrent writes a valid token for now, as returned by GetToken,
+// followed by a newline to w, for CLIs and tools that use the token out of
+// band. Trim the newline with strings.TrimSpace if it is not wanted.
+func (p *TokenProvider) WriteCurrent(w io.Writer, now time.Time) error {
+	tok, err := p.GetToken(now)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, tok+"\n"); err != nil {
+		return fmt.Errorf("failed to write token: %w", err)
+	}
+	return nil
+}
+
+// startRefresh regenerates the token in the background unless a refresh is
+// already running. expireAt identifies the cached token being replaced.
+func (p *TokenProvider) startRefresh(expireAt time.Time) {
+	p.refreshMu.Lock()
+	if p.refreshDone != nil {
+		p.refreshMu.Unlock()
+		return
+	}
+	done := make(chan struct{})
+	p.refreshDone = done
+	p.refreshMu.Unlock()
+
+	go func() {
+		defer func() {
+			p.refreshMu.Lock()
+			p.refreshDone = nil
+			p.refreshMu.Unlock()
+			close(done)
+		}()
+
+		p.writeLock.Lock()
+		defer p.writeLock.Unlock()
+		if c := p.cache.Load().(cachedToken); !c.ExpireAt.Equal(expireAt) {
+			return // already replaced by another caller
+		}
+		if _, err := p.generate(p.now()); err != nil {
+			p.log(slog.LevelWarn, "Background token refresh failed", "err", err)
+		}
+	}()
+}
+
+// Flush waits for an in-progress background refresh to complete.
+// It returns ctx.Err() if ctx is done first, and returns immediately when
+// no background work is running.
+func (p *TokenProvider) Flush(ctx context.Context) error {
+	p.refreshMu.Lock()
+	done := p.refreshDone
+	p.refreshMu.Unlock()
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RotateKey replaces the signer and key ID used for new tokens and discards
+// the cached token, so the next GetToken call signs with the new key. The
+// "alg" header of new tokens follows the signer (see Signer).
+func (p *TokenProvider) RotateKey(signer Signer, keyID string) {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	p.signer = signer
+	p.keyID = keyID
+	p.cache.Store(cachedToken{})
+	p.sigCache.reset()
+
+	p.log(slog.LevelInfo, "Signing key rotated", "key_id", keyID)
+}
+
+// SetTTL changes the time-to-live of tokens at runtime. The TTL is clamped to
+// the maximum set with WithMaxTTL, as at creation. The expiry of the cached
+// token is moved accordingly, so it is regenerated once it is older than the
+// new TTL; with a shorter TTL that may be on the next GetToken call.
+// SetTTL returns an error if d is not positive.
+func (p *TokenProvider) SetTTL(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("invalid token TTL %v: must be positive", d)
+	}
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	if p.maxTTL > 0 && d > p.maxTTL {
+		p.log(slog.LevelWarn, "Token TTL exceeds the maximum, clamping", "ttl", d, "max_ttl", p.maxTTL)
+		d = p.maxTTL
+	}
+	if c := p.cache.Load().(cachedToken); c.Token != "" {
+		c.ExpireAt = c.ExpireAt.Add(d - p.tokenTTL)
+		p.cache.Store(c)
+	}
+	p.tokenTTL = d
+
+	p.log(slog.LevelInfo, "Token TTL changed", "ttl", d)
+	return nil
+}
+
+// SetDevelopment switches the provider between development and production
+// mode. In development mode the claims set with WithDevelopmentClaims are
+// added to new tokens. Changing the mode discards the cached token.
+func (p *TokenProvider) SetDevelopment(dev bool) {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	if p.development == dev {
+		return
+	}
+	p.development = dev
+	p.cache.Store(cachedToken{})
+
+	p.log(slog.LevelInfo, "Token environment changed", "development", dev)
+}
+
+// reloadKey fetches the signer and key ID from the key source if a reload is due.
+// A failed reload keeps the current key; it is an error only for the initial load.
+func (p *TokenProvider) reloadKey() error {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	now := p.now()
+	reloadAt := p.keyReloadAt.Load()
+	if now.UnixNano() < reloadAt {
+		return nil
+	}
+	next := int64(math.MaxInt64)
+	if p.keyInterval > 0 {
+		next = now.Add(p.keyInterval).UnixNano()
+	}
+	p.keyReloadAt.Store(next)
+
+	signer, keyID, err := p.keySource()
+	if err != nil {
+		if reloadAt == 0 {
+			p.keyReloadAt.Store(0) // retry the initial load on the next call
+			return fmt.Errorf("failed to load signing key: %w", err)
+		}
+		p.log(slog.LevelWarn, "Failed to reload signing key, keeping current key", "err", err)
+		return nil
+	}
+
+	p.signer = signer
+	p.sigCache.reset()
+	if keyID != p.keyID {
+		p.keyID = keyID
+		p.cache.Store(cachedToken{})
+		p.log(slog.LevelInfo, "Signing key reloaded", "key_id", keyID)
+	}
+	return nil
+}
+
+// log emits a record at level unless it is below the configured threshold.
+func (p *TokenProvider) log(level slog.Level, msg string, args ...any) {
+	if p.logLevel != nil && level < p.logLevel.Level() {
+		return
+	}
+	p.logger.Log(context.Background(), level, msg, args...)
+}
+
 // LoadPKCS8File loads an ECDSA private key from a PKCS#8 PEM file.
 //
 // Parameters:
@@ -132,19 +623,75 @@ func LoadPKCS8File(path string) (*ecdsa.PrivateKey, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %q: %w", path, err)
 	}
+	return parsePKCS8File(data, path)
+}
+
+// LoadProviderFromDir builds a provider from the key in dir, following the
+// AuthKey_<KEYID>.p8 naming Apple uses for downloaded keys. The key ID is
+// taken from the file name. dir must contain exactly one such file.
+func LoadProviderFromDir(dir, teamID string, opts ...Option) (Provider, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key directory %q: %w", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, "AuthKey_") && strings.HasSuffix(name, ".p8") && len(name) > len("AuthKey_.p8") {
+			names = append(names, name)
+		}
+	}
+	switch len(names) {
+	case 0:
+		return nil, fmt.Errorf("no AuthKey_<KEYID>.p8 file found in %q", dir)
+	case 1:
+	default:
+		return nil, fmt.Errorf("multiple keys found in %q: %s", dir, strings.Join(names, ", "))
+	}
+
+	keyID := strings.TrimSuffix(strings.TrimPrefix(names[0], "AuthKey_"), ".p8")
+	key, err := LoadPKCS8File(filepath.Join(dir, names[0]))
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(keyID, teamID, key, opts...), nil
+}
+
+// LoadPKCS8 loads an ECDSA private key from PKCS#8 PEM data, such as a key
+// read from a secret manager.
+func LoadPKCS8(data []byte) (*ecdsa.PrivateKey, error) {
+	return parsePKCS8(data, "PEM data")
+}
+
+// LoadPKCS8String loads an ECDSA private key from a PKCS#8 PEM string, such
+// as a key embedded in configuration. Surrounding whitespace is trimmed and
+// CRLF line endings are normalized before parsing.
+func LoadPKCS8String(s string) (*ecdsa.PrivateKey, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "\r\n", "\n")
+	return LoadPKCS8([]byte(s))
+}
+
+// parsePKCS8File parses the contents of the PKCS#8 PEM file at path.
+func parsePKCS8File(data []byte, path string) (*ecdsa.PrivateKey, error) {
+	return parsePKCS8(data, fmt.Sprintf("file %q", path))
+}
+
+// parsePKCS8 parses PKCS#8 PEM data holding an ECDSA private key. source
+// describes where the data came from in error messages.
+func parsePKCS8(data []byte, source string) (*ecdsa.PrivateKey, error) {
 	block, _ := pem.Decode(data)
 	if block == nil {
-		return nil, fmt.Errorf("file %q does not contain valid PEM data", path)
+		return nil, fmt.Errorf("%s does not contain valid PEM data", source)
 	}
 
 	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse private key from file %q: %w", path, err)
+		return nil, fmt.Errorf("failed to parse private key from %s: %w", source, err)
 	}
 
 	privKey, ok := key.(*ecdsa.PrivateKey)
 	if !ok {
-		return nil, fmt.Errorf("private key from file %q is not an ECDSA key (actual type: %T)", path, key)
+		return nil, fmt.Errorf("private key from %s is not an ECDSA key (actual type: %T)", source, key)
 	}
 
 	return privKey, nil
@@ -0,0 +1,86 @@
+package token_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+// garbageSigner returns random bytes of the right length instead of a
+// signature, simulating a broken signer.
+type garbageSigner struct {
+	*token.SignerECDSA
+}
+
+func (s *garbageSigner) Sign([]byte) ([]byte, error) {
+	sig := make([]byte, 64)
+	rand.Read(sig)
+	return sig, nil
+}
+
+// opaqueSigner hides the public key of the wrapped signer.
+type opaqueSigner struct {
+	s token.Signer
+}
+
+func (s opaqueSigner) Sign(data []byte) ([]byte, error) { return s.s.Sign(data) }
+
+func TestTokenProvider_WithSelfVerify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+	ecSigner := &token.SignerECDSA{PrivateKey: ecKey, Hash: crypto.SHA256}
+
+	tests := map[string]struct {
+		signer  token.Signer
+		wantErr bool
+	}{
+		"ECDSA":         {signer: ecSigner},
+		"RSA":           {signer: &token.SignerRSA{PrivateKey: rsaKey, Hash: crypto.SHA256}},
+		"Ed25519":       {signer: &token.SignerEd25519{PrivateKey: edKey}},
+		"garbage":       {signer: &garbageSigner{ecSigner}, wantErr: true},
+		"no public key": {signer: opaqueSigner{ecSigner}, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tp := token.NewProvider("ABC123DEFG", "TEAMID1234", nil, token.WithSelfVerify()).(*token.TokenProvider)
+			tp.RotateKey(tt.signer, "ABC123DEFG")
+
+			tok, err := tp.GetToken(time.Now())
+			if tt.wantErr {
+				if !errors.Is(err, token.ErrSelfVerifyFailed) {
+					t.Fatalf("GetToken error = %v, want %v", err, token.ErrSelfVerifyFailed)
+				}
+				return
+			}
+			if err != nil || tok == "" {
+				t.Fatalf("GetToken = %q, %v; want a token", tok, err)
+			}
+		})
+	}
+
+	// Without the option, a broken signer goes unnoticed.
+	tp := token.NewProvider("ABC123DEFG", "TEAMID1234", nil).(*token.TokenProvider)
+	tp.RotateKey(&garbageSigner{ecSigner}, "ABC123DEFG")
+	if _, err := tp.GetToken(time.Now()); err != nil {
+		t.Errorf("GetToken without self-verify failed: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// WatchKeyFile polls the PKCS#8 PEM file at path every interval and calls
+// onReload with the parsed key whenever the file content changes, so a
+// rotated .p8 file can be applied with TokenProvider.RotateKey. Failures are
+// passed to onReload with a nil key: a read failure once until the file can
+// be read again, and invalid content once per change.
+//
+// The file is read once before WatchKeyFile returns; an error is returned if
+// it cannot be read. Polling then continues in the background until ctx is done.
+func WatchKeyFile(ctx context.Context, path string, interval time.Duration, onReload func(*ecdsa.PrivateKey, error)) error {
+	if interval <= 0 {
+		return errors.New("watch interval must be positive")
+	}
+	last, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file %q: %w", path, err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		readFailed := false
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				// A rotation may briefly remove the file; report it only once.
+				if !readFailed {
+					readFailed = true
+					onReload(nil, fmt.Errorf("failed to read file %q: %w", path, err))
+				}
+				continue
+			}
+			readFailed = false
+			if bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			onReload(parsePKCS8File(data, path))
+		}
+	}()
+	return nil
+}
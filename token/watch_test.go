@@ -0,0 +1,95 @@
+package token_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/takimoto3/appleapi-core/token"
+)
+
+type reloadEvent struct {
+	key *ecdsa.PrivateKey
+	err error
+}
+
+// replaceFile atomically replaces path with data, as key rotation tooling
+// should, so the watcher never sees a partially written file.
+func replaceFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("failed to rename file: %v", err)
+	}
+}
+
+func TestWatchKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := generateECDSAP8Key(t, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan reloadEvent, 10)
+	err := token.WatchKeyFile(ctx, path, 5*time.Millisecond, func(key *ecdsa.PrivateKey, err error) {
+		events <- reloadEvent{key, err}
+	})
+	if err != nil {
+		t.Fatalf("WatchKeyFile failed: %v", err)
+	}
+
+	next := func() reloadEvent {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the reload callback")
+			return reloadEvent{}
+		}
+	}
+
+	// Writing a new key fires the callback with that key.
+	newPath := generateECDSAP8Key(t, t.TempDir())
+	want, err := token.LoadPKCS8File(newPath)
+	if err != nil {
+		t.Fatalf("LoadPKCS8File failed: %v", err)
+	}
+	data, err := os.ReadFile(newPath)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	replaceFile(t, path, data)
+	if ev := next(); ev.err != nil || !want.Equal(ev.key) {
+		t.Errorf("callback = (%v, %v), want the new key", ev.key != nil, ev.err)
+	}
+
+	// Invalid content is reported as an error.
+	replaceFile(t, path, []byte("not a key"))
+	if ev := next(); ev.err == nil || ev.key != nil {
+		t.Errorf("callback = (%v, %v), want an error", ev.key != nil, ev.err)
+	}
+
+	// No callbacks after the context is done.
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	replaceFile(t, path, data)
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected callback after cancel: (%v, %v)", ev.key != nil, ev.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatchKeyFile_MissingFile(t *testing.T) {
+	err := token.WatchKeyFile(context.Background(), filepath.Join(t.TempDir(), "missing.p8"), time.Second,
+		func(*ecdsa.PrivateKey, error) {})
+	if err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
@@ -1,9 +1,13 @@
 package appleapi
 
 import (
+	"context"
 	"crypto/tls"
 	"log/slog"
+	"net/http"
 	"net/http/httptrace"
+	"sync/atomic"
+	"time"
 )
 
 // DefaultClientTrace returns a ClientTrace with all callbacks implemented
@@ -12,17 +16,33 @@ func DefaultClientTrace(logger *slog.Logger, level slog.Level) *httptrace.Client
 	if logger == nil {
 		panic("logger cannot be nil for DefaultClientTrace")
 	}
+	return newClientTrace(logger, level, level)
+}
+
+// LeveledClientTrace is like DefaultClientTrace, but the callbacks that
+// report a failure (DNSDone, ConnectDone, TLSHandshakeDone and WroteRequest)
+// log at errLevel instead of level when their error is non-nil. PutIdleConn
+// errors are routine (for example a full idle pool) and stay at level.
+func LeveledClientTrace(logger *slog.Logger, level, errLevel slog.Level) *httptrace.ClientTrace {
+	if logger == nil {
+		panic("logger cannot be nil for LeveledClientTrace")
+	}
+	return newClientTrace(logger, level, errLevel)
+}
 
-	var log func(string, ...any)
-	switch level {
-	case slog.LevelDebug:
-		log = logger.Debug
-	case slog.LevelInfo:
-		log = logger.Info
-	case slog.LevelWarn:
-		log = logger.Warn
-	case slog.LevelError:
-		log = logger.Error
+// newClientTrace builds the trace hooks of DefaultClientTrace, logging
+// successful events at level and failed ones at errLevel.
+func newClientTrace(logger *slog.Logger, level, errLevel slog.Level) *httptrace.ClientTrace {
+	ctx := context.Background()
+	log := func(msg string, args ...any) {
+		logger.Log(ctx, level, msg, args...)
+	}
+	logErr := func(err error, msg string, args ...any) {
+		lvl := level
+		if err != nil {
+			lvl = errLevel
+		}
+		logger.Log(ctx, lvl, msg, args...)
 	}
 
 	return &httptrace.ClientTrace{
@@ -53,7 +73,7 @@ func DefaultClientTrace(logger *slog.Logger, level slog.Level) *httptrace.Client
 			for i, a := range info.Addrs {
 				addrs[i] = a.String()
 			}
-			log("DNSDone",
+			logErr(info.Err, "DNSDone",
 				slog.Any("addrs", addrs),
 				slog.Any("err", info.Err),
 				slog.Bool("coalesced", info.Coalesced),
@@ -68,7 +88,7 @@ func DefaultClientTrace(logger *slog.Logger, level slog.Level) *httptrace.Client
 		},
 
 		ConnectDone: func(network, addr string, err error) {
-			log("ConnectDone",
+			logErr(err, "ConnectDone",
 				slog.String("network", network),
 				slog.String("addr", addr),
 				slog.Any("err", err),
@@ -80,7 +100,7 @@ func DefaultClientTrace(logger *slog.Logger, level slog.Level) *httptrace.Client
 		},
 
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			log("TLSHandshakeDone",
+			logErr(err, "TLSHandshakeDone",
 				slog.String("serverName", state.ServerName),
 				slog.Bool("handshakeComplete", state.HandshakeComplete),
 				slog.Any("err", err),
@@ -95,7 +115,7 @@ func DefaultClientTrace(logger *slog.Logger, level slog.Level) *httptrace.Client
 		},
 
 		WroteRequest: func(info httptrace.WroteRequestInfo) {
-			log("WroteRequest", slog.Any("err", info.Err))
+			logErr(info.Err, "WroteRequest", slog.Any("err", info.Err))
 		},
 
 		GotFirstResponseByte: func() {
@@ -113,3 +133,191 @@ func DefaultClientTrace(logger *slog.Logger, level slog.Level) *httptrace.Client
 		},
 	}
 }
+
+// requestSize accumulates the approximate number of bytes written for a single request.
+type requestSize struct {
+	header atomic.Int64 // bytes of header fields reported by WroteHeaderField
+}
+
+// trace returns hooks that count the header fields written for the request.
+func (s *requestSize) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		WroteHeaderField: func(key string, values []string) {
+			var n int
+			for _, v := range values {
+				n += len(key) + len(v) + 4 // "key: value\r\n"
+			}
+			s.header.Add(int64(n))
+		},
+	}
+}
+
+// bytes returns the header bytes written so far plus the request body length, if known.
+func (s *requestSize) bytes(req *http.Request) int64 {
+	n := s.header.Load()
+	if body, ok := requestBodyLength(req); ok {
+		n += body
+	}
+	return n
+}
+
+// requestBodyLength returns the body length of req and whether it is known.
+// A zero ContentLength only means an empty body when there is no Body.
+func requestBodyLength(req *http.Request) (int64, bool) {
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+		return 0, true
+	case req.ContentLength > 0:
+		return req.ContentLength, true
+	}
+	return 0, false
+}
+
+// requestTrace returns the trace hooks for a request, honoring a decision
+// made with ContextWithTrace before the client's trace sampling.
+func (c *Client) requestTrace(ctx context.Context, logger *slog.Logger) *httptrace.ClientTrace {
+	enabled, forced := contextTrace(ctx)
+	if forced && !enabled {
+		return nil
+	}
+	trace := c.Trace
+	if forced {
+		if trace == nil {
+			return DefaultClientTrace(c.traceLogger(logger), slog.LevelDebug)
+		}
+	} else if trace != nil && c.traceSample > 1 && (c.traceCount.Add(1)-1)%c.traceSample != 0 {
+		return nil
+	}
+	if logger != c.Logger && c.traceFunc != nil {
+		// Rebuild the trace hooks so they write to the request-scoped logger.
+		if tr := c.traceFunc(c.traceLogger(logger)); tr != nil {
+			trace = tr
+		}
+	}
+	return trace
+}
+
+// traceLogger returns logger filtered by the level set with WithLogLevel, for
+// trace hooks, which write to their logger directly rather than through c.log.
+func (c *Client) traceLogger(logger *slog.Logger) *slog.Logger {
+	if c.logLevel == nil {
+		return logger
+	}
+	return slog.New(&levelHandler{Handler: logger.Handler(), level: c.logLevel})
+}
+
+// levelHandler drops records below level before they reach Handler.
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+// Enabled reports whether l is at or above the handler's level and enabled
+// by the wrapped handler.
+func (h *levelHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= h.level.Level() && h.Handler.Enabled(ctx, l)
+}
+
+// Handle passes r to the wrapped handler unless it is below the level.
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.level.Level() {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a levelHandler wrapping the wrapped handler's WithAttrs.
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+// WithGroup returns a levelHandler wrapping the wrapped handler's WithGroup.
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// connReuseTrace returns hooks that warn when a request after the client's
+// first one obtains a new connection rather than a reused one.
+func (c *Client) connReuseTrace(ctx context.Context, logger *slog.Logger) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			first := c.gotFirstConn.CompareAndSwap(false, true)
+			if first || info.Reused {
+				return
+			}
+			remoteAddr := "nil"
+			if info.Conn != nil {
+				remoteAddr = info.Conn.RemoteAddr().String()
+			}
+			c.log(ctx, logger, slog.LevelWarn, "Connection not reused", slog.String("remoteAddr", remoteAddr))
+		},
+	}
+}
+
+// traceHeaders are the response headers logged by WithTraceHeaderLogging,
+// with the attribute key used for each.
+var traceHeaders = []struct{ header, key string }{
+	{"Traceparent", "traceparent"},
+	{"B3", "b3"},
+	{"X-B3-Traceid", "b3TraceId"},
+}
+
+// appendTraceHeaders appends an attribute for each trace propagation header present in h.
+func appendTraceHeaders(attrs []slog.Attr, h http.Header) []slog.Attr {
+	for _, th := range traceHeaders {
+		if v := h.Get(th.header); v != "" {
+			attrs = append(attrs, slog.String(th.key, v))
+		}
+	}
+	return attrs
+}
+
+// ConnStats holds connection counters aggregated over a client's requests
+// since it was created.
+type ConnStats struct {
+	NewConns         uint64        // Requests that obtained a newly dialed connection
+	ReusedConns      uint64        // Requests that reused a pooled connection
+	TLSHandshakes    uint64        // Successful TLS handshakes
+	TLSHandshakeTime time.Duration // Total time spent in successful TLS handshakes
+}
+
+// connStats aggregates connection events reported by request traces.
+type connStats struct {
+	newConns       atomic.Uint64
+	reusedConns    atomic.Uint64
+	handshakes     atomic.Uint64
+	handshakeNanos atomic.Int64
+}
+
+// trace returns hooks that record the connection events of one request.
+func (s *connStats) trace() *httptrace.ClientTrace {
+	var start atomic.Int64
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				s.reusedConns.Add(1)
+			} else {
+				s.newConns.Add(1)
+			}
+		},
+		TLSHandshakeStart: func() {
+			start.Store(time.Now().UnixNano())
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if t := start.Load(); t != 0 && err == nil {
+				s.handshakes.Add(1)
+				s.handshakeNanos.Add(time.Now().UnixNano() - t)
+			}
+		},
+	}
+}
+
+// snapshot returns the current counter values.
+func (s *connStats) snapshot() ConnStats {
+	return ConnStats{
+		NewConns:         s.newConns.Load(),
+		ReusedConns:      s.reusedConns.Load(),
+		TLSHandshakes:    s.handshakes.Load(),
+		TLSHandshakeTime: time.Duration(s.handshakeNanos.Load()),
+	}
+}
@@ -3,14 +3,18 @@ package appleapi_test
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"log/slog"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/http/httptrace"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/takimoto3/appleapi-core"
+	"github.com/takimoto3/appleapi-core/token"
 )
 
 // --- captureHandler and mocks ---
@@ -199,9 +203,124 @@ func TestDefaultClientTrace_TableDriven(t *testing.T) {
 	}
 }
 
+func TestLeveledClientTrace(t *testing.T) {
+	var logs []slog.Record
+	logger := slog.New(&captureHandler{logs: &logs})
+	trace := appleapi.LeveledClientTrace(logger, slog.LevelDebug, slog.LevelWarn)
+	failure := errors.New("failure")
+
+	tests := map[string]struct {
+		call      func()
+		wantMsg   string
+		wantLevel slog.Level
+	}{
+		"ConnectDone success": {
+			call:      func() { trace.ConnectDone("tcp", "example.com:443", nil) },
+			wantMsg:   "ConnectDone",
+			wantLevel: slog.LevelDebug,
+		},
+		"ConnectDone error": {
+			call:      func() { trace.ConnectDone("tcp", "example.com:443", failure) },
+			wantMsg:   "ConnectDone",
+			wantLevel: slog.LevelWarn,
+		},
+		"TLSHandshakeDone success": {
+			call:      func() { trace.TLSHandshakeDone(tls.ConnectionState{}, nil) },
+			wantMsg:   "TLSHandshakeDone",
+			wantLevel: slog.LevelDebug,
+		},
+		"TLSHandshakeDone error": {
+			call:      func() { trace.TLSHandshakeDone(tls.ConnectionState{}, failure) },
+			wantMsg:   "TLSHandshakeDone",
+			wantLevel: slog.LevelWarn,
+		},
+		"DNSDone error": {
+			call:      func() { trace.DNSDone(httptrace.DNSDoneInfo{Err: failure}) },
+			wantMsg:   "DNSDone",
+			wantLevel: slog.LevelWarn,
+		},
+		"WroteRequest error": {
+			call:      func() { trace.WroteRequest(httptrace.WroteRequestInfo{Err: failure}) },
+			wantMsg:   "WroteRequest",
+			wantLevel: slog.LevelWarn,
+		},
+		"PutIdleConn error stays at base level": {
+			call:      func() { trace.PutIdleConn(failure) },
+			wantMsg:   "PutIdleConn",
+			wantLevel: slog.LevelDebug,
+		},
+		"GetConn": {
+			call:      func() { trace.GetConn("example.com:443") },
+			wantMsg:   "GetConn",
+			wantLevel: slog.LevelDebug,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			logs = nil
+			tt.call()
+
+			if len(logs) != 1 {
+				t.Fatalf("expected 1 log, got %d", len(logs))
+			}
+			if logs[0].Message != tt.wantMsg || logs[0].Level != tt.wantLevel {
+				t.Errorf("got %q at %v, want %q at %v", logs[0].Message, logs[0].Level, tt.wantMsg, tt.wantLevel)
+			}
+		})
+	}
+}
+
 // helper: create expected record easily
 func makeRecord(msg string, attrs ...slog.Attr) slog.Record {
 	r := slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0)
 	r.AddAttrs(attrs...)
 	return r
 }
+
+func TestClient_ConnStats(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	init := func() (*http.Client, error) { return srv.Client(), nil }
+	c, err := appleapi.NewClient(init, srv.URL, token.NewStaticProvider("tok"), appleapi.WithConnStats())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	do := func(ctx context.Context) {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		appleapi.DrainBody(resp)
+	}
+
+	for range 3 {
+		do(context.Background()) // one new connection, then two reuses
+	}
+	do(appleapi.ContextForceNewConn(context.Background()))
+	c.CloseIdleConnections()
+	do(context.Background())
+
+	got := c.ConnStats()
+	if got.TLSHandshakeTime <= 0 {
+		t.Errorf("TLSHandshakeTime = %v, want > 0", got.TLSHandshakeTime)
+	}
+	got.TLSHandshakeTime = 0
+	want := appleapi.ConnStats{NewConns: 3, ReusedConns: 2, TLSHandshakes: 3}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ConnStats mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_ConnStats_Disabled(t *testing.T) {
+	c, err := appleapi.NewClient(appleapi.DefaultHTTPClientInitializer(), "", token.NewStaticProvider("tok"))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if got := c.ConnStats(); got != (appleapi.ConnStats{}) {
+		t.Errorf("ConnStats() = %+v, want zero when disabled", got)
+	}
+}